@@ -14,6 +14,7 @@ import (
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
+	"admitee/pkg/metrics"
 	"admitee/pkg/server"
 	"admitee/pkg/server/config"
 	"admitee/pkg/server/options"
@@ -55,15 +56,6 @@ func NewCommand(ctx context.Context) *cobra.Command {
 func Run(ctx context.Context, opts *options.Options) error {
 	var eg errgroup.Group
 
-	clientRedis, err := opts.NewClientRedis()
-	if err != nil {
-		glog.Errorf("FAILURE: NewClientRedis[%v]", err)
-
-		panic(err)
-	} else {
-		glog.Infof("Initial ClientRedis.")
-	}
-
 	clientSmooth, err := NewClientSmooth()
 	if err != nil {
 		glog.Errorf("FAILURE: NewClientSmooth[%v]", err)
@@ -82,13 +74,23 @@ func Run(ctx context.Context, opts *options.Options) error {
 		glog.Infof("Initial ClientKubeSet.")
 	}
 
+	store, err := opts.NewStore(clientKubeSet)
+	if err != nil {
+		glog.Errorf("FAILURE: NewStore[%s][%v]", opts.Store, err)
+
+		panic(err)
+	} else {
+		glog.Infof("Initial Store[%s].", opts.Store)
+	}
+	store = metrics.InstrumentStore(store, opts.Store)
+
 	eg.Go(func() error {
 		// Start admitee server
 		serverConfig := config.NewServerConfig()
 		if err := opts.ApplyTo(serverConfig); err != nil {
 			glog.Exit(err)
 		}
-		server, err := server.NewServer(serverConfig, clientSmooth, clientKubeSet, clientRedis)
+		server, err := server.NewServer(serverConfig, clientSmooth, clientKubeSet, store)
 		if err != nil {
 			glog.Exit(err)
 		}
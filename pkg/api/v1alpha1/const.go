@@ -7,3 +7,19 @@ const (
 	Resource   = "smooths"
 	LabelForce = "admiteed-smooth-force"
 )
+
+const (
+	// ResourceCluster cluster-scoped ClusterSmooth, fallback of pods without a Smooth
+	ResourceCluster = "clustersmooths"
+	KindCluster     = "ClusterSmooth"
+)
+
+// AnnotationAbort "true" on a pod aborts its smoothing, on a Smooth aborts every pod smoothing by it
+const AnnotationAbort = "validating.example.com/abort-smoothing"
+
+// AnnotationSmoothing start time of the smoothing of a pod, set by admission so the leader picks the pod up
+const AnnotationSmoothing = "validating.example.com/smoothing-since"
+
+// ReadinessGateType condition injected by the /mutate/smooth webhook, set by admiteed.
+// True while the pod serves, False once its smoothing starts
+const ReadinessGateType = "validating.example.com/serving"
@@ -2,7 +2,9 @@ package v1alpha1
 
 import (
 	autoscalingv2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -10,15 +12,77 @@ const (
 	DefaultTimeout  = 24 // hours, timeout for per SmoothProcess
 	DefaultPort     = 80
 	DefaultMethod   = "get"
+	DefaultScheme   = "http"
+	DefaultBackoff  = 1 // seconds, wait before the first retry of a rule
+)
+
+const (
+	// TimeoutPolicyAllow the next delete after timeout is allowed without rules
+	TimeoutPolicyAllow = "Allow"
+	// TimeoutPolicyDeny deletes are still denied until the rules pass, the smoothing loop stops retrying
+	TimeoutPolicyDeny = "Deny"
+	// TimeoutPolicyDelete the smoothing loop deletes the pod, the delete is allowed without rules
+	TimeoutPolicyDelete = "Delete"
+)
+
+const (
+	// FailurePolicyFail deletes are denied while the state store or apiserver can not be reached
+	FailurePolicyFail = "Fail"
+	// FailurePolicyIgnore deletes are allowed without smoothing while the state store or apiserver can not be reached
+	FailurePolicyIgnore = "Ignore"
+)
+
+const (
+	// EvaluationSync rules are probed inside the admission request
+	EvaluationSync = "Sync"
+	// EvaluationAsync admission answers from rule results probed in background
+	EvaluationAsync = "Async"
+)
+
+const (
+	// ConditionReady the Smooth is valid and its target exists
+	ConditionReady = "Ready"
+	// ConditionTargetFound the workload referenced by targetRef exists
+	ConditionTargetFound = "TargetFound"
+	// ConditionConflict another Smooth matches some pods of a selector Smooth
+	ConditionConflict = "Conflict"
 )
 
 type Rule struct {
-	Address string `json:"address"` // request address, default pod ip
-	Port    int    `json:"port"`    // request port
-	Path    string `json:"path"`    // request path
-	Method  string `json:"method"`  // request method
-	Body    string `json:"body"`    // request body for post method
-	Expect  string `json:"expect"`  // expect response body
+	Scheme  string   `json:"scheme,omitempty"` // http or https, default http
+	Address string   `json:"address"`          // request address, default pod ip
+	Port    int      `json:"port"`             // request port
+	Path    string   `json:"path"`             // request path
+	Method  string   `json:"method"`           // request method
+	Body    string   `json:"body"`             // request body for post method
+	Expect  string   `json:"expect"`           // expect response body
+	TLS     *RuleTLS `json:"tls,omitempty"`    // trust configuration of https rules
+	// Headers of the request, address, path, body and header values are go templates of RuleVars, e.g. {{ .PodName }}
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout of one attempt, default 10s
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	Retries int              `json:"retries,omitempty"` // attempts after a request error
+	Backoff *metav1.Duration `json:"backoff,omitempty"` // wait before the first retry, doubled for the next ones
+	// Matchers all must pass, expect is then only checked when set.
+	// Without a status matcher any status but 200 is a request error
+	Matchers []Matcher `json:"matchers,omitempty"`
+}
+
+// Matcher one assertion on the response, exactly one of status, regex, jsonPath and header is set
+type Matcher struct {
+	Status   []int  `json:"status,omitempty"`   // status code is one of
+	Regex    string `json:"regex,omitempty"`    // response body matches
+	JSONPath string `json:"jsonPath,omitempty"` // path exists, or compares with "$.connections <= 0", operators == != < <= > >=
+	Header   string `json:"header,omitempty"`   // response header exists
+	Value    string `json:"value,omitempty"`    // regex the header value matches
+	Negate   bool   `json:"negate,omitempty"`   // pass when the assertion fails
+}
+
+type RuleTLS struct {
+	// SecretName in the namespace of the Smooth, ca.crt verifies the server, tls.crt and tls.key are the client certificate
+	SecretName         string `json:"secretName,omitempty"`
+	ServerName         string `json:"serverName,omitempty"` // SNI and verified hostname, default the request host
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
 }
 
 type SmoothSpec struct {
@@ -26,8 +90,65 @@ type SmoothSpec struct {
 	TargetRef autoscalingv2.CrossVersionObjectReference `json:"targetRef"`
 	Rules     []Rule                                    `json:"rules"`
 	Interval  int                                       `json:"interval"`
-	Timeout   int                                       `json:"timeout"`
+	Timeout   intstr.IntOrString                        `json:"timeout"` // hours as int, or a duration such as "90m"
 	SmLabel   string                                    `json:"smLabel"`
+	// Selector of pods, alternative to targetRef for a fleet of workloads
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Priority among selector Smooths matching the same pod, targetRef always wins
+	Priority int `json:"priority,omitempty"`
+	// TimeoutPolicy Allow, Deny or Delete once timeout passes, default Allow
+	TimeoutPolicy string `json:"timeoutPolicy,omitempty"`
+	// FailurePolicy Fail or Ignore when the state store or apiserver is unavailable, default the --failure-policy of admiteed
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// Evaluation Sync or Async, default the --rule-evaluation of admiteed
+	Evaluation string `json:"evaluation,omitempty"`
+	// MaxConcurrent pods smoothing at once, int or percent of ready replicas of the target
+	MaxConcurrent *intstr.IntOrString `json:"maxConcurrent,omitempty"`
+	// MinAvailable ready replicas kept while smoothing, int or percent of desired replicas of the target
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// RestoreRules requested in order when smoothing is aborted, e.g. post false to /isolation
+	RestoreRules []Rule `json:"restoreRules,omitempty"`
+	// Inject into pods created for the Smooth by the /mutate/smooth webhook
+	Inject *Inject `json:"inject,omitempty"`
+}
+
+// Inject pod changes applied at creation, traffic isolation then needs no readiness tricks in the app
+type Inject struct {
+	// ReadinessGate adds the readiness gate validating.example.com/serving controlled by admiteed
+	ReadinessGate bool `json:"readinessGate,omitempty"`
+	// PreStop of containers without one, e.g. wait for connections to drain
+	PreStop *corev1.LifecycleHandler `json:"preStop,omitempty"`
+	// Containers preStop is applied to, default every container
+	Containers []string `json:"containers,omitempty"`
+	// Sidecars appended unless a container of the same name exists
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+}
+
+type RuleResult struct {
+	Pod       string      `json:"pod"`                // pod the rule was probed for
+	Rule      string      `json:"rule"`               // method port path
+	Response  string      `json:"response,omitempty"` // response body, truncated
+	Expect    string      `json:"expect,omitempty"`   // expect response body
+	Passed    bool        `json:"passed"`             // false when the rule blocks the delete
+	Error     string      `json:"error,omitempty"`    // request error
+	ProbeTime metav1.Time `json:"probeTime,omitempty"`
+}
+
+type SmoothingPod struct {
+	Name          string      `json:"name"`
+	Attempts      int         `json:"attempts"` // delete attempts by the smoothing loop
+	FirstSeen     metav1.Time `json:"firstSeen,omitempty"`
+	LastProbeTime metav1.Time `json:"lastProbeTime,omitempty"`
+	RequestedBy   string      `json:"requestedBy,omitempty"` // user of the first delete request
+	Reason        string      `json:"reason,omitempty"`      // why the pod is still blocked
+}
+
+type SmoothStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	SmoothingCount     int                `json:"smoothingCount"`
+	SmoothingPods      []SmoothingPod     `json:"smoothingPods,omitempty"`
+	LastRuleResults    []RuleResult       `json:"lastRuleResults,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
 }
 
 type Smooth struct {
@@ -36,6 +157,8 @@ type Smooth struct {
 
 	// +optional
 	Spec SmoothSpec `json:"spec,omitempty"`
+	// +optional
+	Status SmoothStatus `json:"status,omitempty"`
 }
 
 type SmoothList struct {
@@ -44,3 +167,25 @@ type SmoothList struct {
 
 	Items []Smooth `json:"items"`
 }
+
+// ClusterSmoothSpec a Smooth applied to pods of selected namespaces, targetRef is not used
+type ClusterSmoothSpec struct {
+	SmoothSpec `json:",inline"`
+	// NamespaceSelector of namespaces the policy applies to, every namespace when not set
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+type ClusterSmooth struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Spec ClusterSmoothSpec `json:"spec,omitempty"`
+}
+
+type ClusterSmoothList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterSmooth `json:"items"`
+}
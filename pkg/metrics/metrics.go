@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "admitee"
+
+var (
+	// AdmissionDecisions reason is a short category, the full reason stays in the webhook response
+	AdmissionDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admission_decisions_total",
+		Help:      "Pod delete and eviction decisions by namespace, target kind, allowed and reason.",
+	}, []string{"namespace", "target_kind", "allowed", "reason"})
+
+	// RuleProbeDuration result is expect, unexpected or error
+	RuleProbeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rule_probe_duration_seconds",
+		Help:      "Latency of rule probes by rule path and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"path", "result"})
+
+	SmoothingPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "smoothing_pods",
+		Help:      "Pods currently in smoothing process by target.",
+	}, []string{"namespace", "target_kind", "target"})
+
+	// SmoothingDuration from the first delete attempt of a pod to its allowed delete
+	SmoothingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "smoothing_duration_seconds",
+		Help:      "Time from the first delete attempt to the allowed delete by namespace and target kind.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 18),
+	}, []string{"namespace", "target_kind"})
+
+	SmoothingTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "smoothing_timeouts_total",
+		Help:      "Pods whose smoothing exceeded the timeout by namespace, target kind and timeout policy.",
+	}, []string{"namespace", "target_kind", "policy"})
+
+	StoreErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_errors_total",
+		Help:      "Errors returned by the smoothing state store by backend and operation.",
+	}, []string{"store", "operation"})
+
+	LockWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_wait_seconds",
+		Help:      "Time spent waiting for the lock of a target.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		AdmissionDecisions,
+		RuleProbeDuration,
+		SmoothingPods,
+		SmoothingDuration,
+		SmoothingTimeouts,
+		StoreErrors,
+		LockWaitDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
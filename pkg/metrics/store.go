@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"admitee/pkg/model"
+)
+
+// instrumentedStore counts errors of the wrapped store
+type instrumentedStore struct {
+	model.Store
+	name string
+}
+
+// InstrumentStore wrap a store so its errors are exported as admitee_store_errors_total
+func InstrumentStore(store model.Store, name string) model.Store {
+	return &instrumentedStore{Store: store, name: name}
+}
+
+func (s *instrumentedStore) observe(operation string, err error) {
+	if err != nil {
+		StoreErrors.WithLabelValues(s.name, operation).Inc()
+	}
+}
+
+func (s *instrumentedStore) Get(kind model.Kind, namespace string, name string) (string, error) {
+	value, err := s.Store.Get(kind, namespace, name)
+	s.observe("get", err)
+	return value, err
+}
+
+func (s *instrumentedStore) Set(kind model.Kind, namespace string, name string, value string) error {
+	err := s.Store.Set(kind, namespace, name, value)
+	s.observe("set", err)
+	return err
+}
+
+func (s *instrumentedStore) SetNX(kind model.Kind, namespace string, name string, value string) (bool, error) {
+	ok, err := s.Store.SetNX(kind, namespace, name, value)
+	s.observe("setnx", err)
+	return ok, err
+}
+
+func (s *instrumentedStore) Del(kind model.Kind, namespace string, name string) error {
+	err := s.Store.Del(kind, namespace, name)
+	s.observe("del", err)
+	return err
+}
+
+func (s *instrumentedStore) Lock(key string) (bool, error) {
+	ok, err := s.Store.Lock(key)
+	s.observe("lock", err)
+	return ok, err
+}
+
+func (s *instrumentedStore) List(kind model.Kind, namespace string) ([]model.Entry, error) {
+	entries, err := s.Store.List(kind, namespace)
+	s.observe("list", err)
+	return entries, err
+}
@@ -0,0 +1,276 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	kubeConfigMapPrefix = "admitee-smooth-"
+	kubeLeasePrefix     = "admitee-lock-"
+	kubeLockDuration    = 10 // seconds, same as redis lock expiration
+
+	// labels select the records of a kind, annotations keep the pod the record belongs to
+	kubeLabelKind           = "validating.example.com/store-kind"
+	kubeLabelNamespace      = "validating.example.com/store-namespace"
+	kubeAnnotationName      = "validating.example.com/store-name"
+	kubeAnnotationNamespace = "validating.example.com/store-namespace"
+	kubeDataValue           = "value"
+	kubeLabelLock           = "validating.example.com/store-lock"
+	// kubeLeaseGC period to delete Leases of locks expired and never released, e.g. by a crashed replica
+	kubeLeaseGC = time.Minute
+)
+
+// AdmiteeKubeStore keeps each record in its own ConfigMap and locks in Leases,
+// so small clusters can run admitee without redis
+type AdmiteeKubeStore struct {
+	Client    kubernetes.Interface
+	Ctx       context.Context
+	Namespace string // namespace of ConfigMaps and Leases
+	Identity  string // holder identity of Leases
+	Health    atomic.Bool
+	sync.Mutex
+}
+
+// kubeHash names objects after a hash, keys may hold any character and exceed object name limits
+func kubeHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func kubeConfigMapName(kind Kind, namespace string, name string) string {
+	return kubeConfigMapPrefix + strings.ToLower(string(kind)) + "-" + kubeHash(namespace+"/"+name)
+}
+
+func kubeLeaseName(key string) string {
+	return kubeLeasePrefix + kubeHash(key)
+}
+
+func (c *AdmiteeKubeStore) Get(kind Kind, namespace string, name string) (string, error) {
+	cm, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Get(c.Ctx, kubeConfigMapName(kind, namespace, name), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return cm.Data[kubeDataValue], nil
+}
+
+func (c *AdmiteeKubeStore) Set(kind Kind, namespace string, name string, value string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Get(c.Ctx, kubeConfigMapName(kind, namespace, name), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = c.Client.CoreV1().ConfigMaps(c.Namespace).Create(c.Ctx, c.newConfigMap(kind, namespace, name, value), metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// created by another replica, retry as a conflict
+				return errors.NewConflict(corev1.Resource("configmaps"), kubeConfigMapName(kind, namespace, name), err)
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+		if cm.Data[kubeDataValue] == value {
+			return nil
+		}
+		cm.Data = map[string]string{kubeDataValue: value}
+		_, err = c.Client.CoreV1().ConfigMaps(c.Namespace).Update(c.Ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (c *AdmiteeKubeStore) SetNX(kind Kind, namespace string, name string, value string) (bool, error) {
+	_, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Create(c.Ctx, c.newConfigMap(kind, namespace, name, value), metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *AdmiteeKubeStore) Del(kind Kind, namespace string, name string) error {
+	err := c.Client.CoreV1().ConfigMaps(c.Namespace).Delete(c.Ctx, kubeConfigMapName(kind, namespace, name), metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *AdmiteeKubeStore) newConfigMap(kind Kind, namespace string, name string, value string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kubeConfigMapName(kind, namespace, name),
+			Namespace: c.Namespace,
+			Labels: map[string]string{
+				"app":              "admiteed",
+				kubeLabelKind:      strings.ToLower(string(kind)),
+				kubeLabelNamespace: namespace,
+			},
+			Annotations: map[string]string{
+				kubeAnnotationNamespace: namespace,
+				kubeAnnotationName:      name,
+			},
+		},
+		Data: map[string]string{kubeDataValue: value},
+	}
+}
+
+func (c *AdmiteeKubeStore) List(kind Kind, namespace string) ([]Entry, error) {
+	selector := kubeLabelKind + "=" + strings.ToLower(string(kind))
+	if namespace != "" {
+		selector += "," + kubeLabelNamespace + "=" + namespace
+	}
+	cms, err := c.Client.CoreV1().ConfigMaps(c.Namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, cm := range cms.Items {
+		name, ok := cm.Annotations[kubeAnnotationName]
+		if !ok {
+			continue
+		}
+		entries = append(entries, Entry{Namespace: cm.Annotations[kubeAnnotationNamespace], Name: name, Value: cm.Data[kubeDataValue]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Namespace+"/"+entries[i].Name < entries[j].Namespace+"/"+entries[j].Name
+	})
+	return entries, nil
+}
+
+func (c *AdmiteeKubeStore) Lock(key string) (bool, error) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	var duration int32 = kubeLockDuration
+	now := metav1.NewMicroTime(time.Now())
+	leases := c.Client.CoordinationV1().Leases(c.Namespace)
+
+	lease, err := leases.Get(c.Ctx, kubeLeaseName(key), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      kubeLeaseName(key),
+				Namespace: c.Namespace,
+				Labels:    map[string]string{"app": "admiteed", kubeLabelLock: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &c.Identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(c.Ctx, lease, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			return false, nil
+		} else if err != nil {
+			glog.Errorf("FAILURE: Lock[%v]", err)
+			return false, err
+		}
+		return true, nil
+	} else if err != nil {
+		glog.Errorf("FAILURE: Lock[%v]", err)
+		return false, err
+	}
+
+	// lock still held by someone
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil &&
+		lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second).After(time.Now()) {
+		return false, nil
+	}
+
+	lease.Spec.HolderIdentity = &c.Identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(c.Ctx, lease, metav1.UpdateOptions{})
+	if errors.IsConflict(err) {
+		return false, nil
+	} else if err != nil {
+		glog.Errorf("FAILURE: Lock[%v]", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *AdmiteeKubeStore) UnLock(key string) int64 {
+	leases := c.Client.CoordinationV1().Leases(c.Namespace)
+	lease, err := leases.Get(c.Ctx, kubeLeaseName(key), metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			glog.Errorf("FAILURE: UnLock[%v]", err)
+		}
+		return 0
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != c.Identity {
+		return 0
+	}
+
+	// the precondition keeps a lease taken over by another replica meanwhile
+	err = leases.Delete(c.Ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil {
+		if !errors.IsNotFound(err) && !errors.IsConflict(err) {
+			glog.Errorf("FAILURE: UnLock[%v]", err)
+		}
+		return 0
+	}
+	return 1
+}
+
+// gcLeases delete lock Leases expired for longer than kubeLeaseGC
+func (c *AdmiteeKubeStore) gcLeases() {
+	leases := c.Client.CoordinationV1().Leases(c.Namespace)
+	list, err := leases.List(c.Ctx, metav1.ListOptions{LabelSelector: kubeLabelLock})
+	if err != nil {
+		glog.Errorf("FAILURE: Lease LIST[%v]", err)
+		return
+	}
+	for i := range list.Items {
+		lease := &list.Items[i]
+		if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil &&
+			lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second+kubeLeaseGC).After(time.Now()) {
+			continue
+		}
+		err := leases.Delete(c.Ctx, lease.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+		})
+		if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+			glog.Errorf("FAILURE: Lease DEL[%s]: %v", lease.Name, err)
+		}
+	}
+}
+
+func (c *AdmiteeKubeStore) Healthy() bool {
+	return c.Health.Load()
+}
+
+func (c *AdmiteeKubeStore) HealthCheck() {
+	lastGC := time.Now()
+	for {
+		_, err := c.Client.CoreV1().ConfigMaps(c.Namespace).List(c.Ctx, metav1.ListOptions{LabelSelector: kubeLabelKind, Limit: 1})
+		c.Health.Store(err == nil)
+		if time.Since(lastGC) >= kubeLeaseGC {
+			c.gcLeases()
+			lastGC = time.Now()
+		}
+		time.Sleep(time.Duration(10) * time.Second)
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdmiteeMemoryStore keeps state in process, only for single replica installs and tests
+type AdmiteeMemoryStore struct {
+	records map[Kind]map[string]Entry
+	locks   map[string]time.Time
+	sync.Mutex
+}
+
+func NewMemoryStore() *AdmiteeMemoryStore {
+	return &AdmiteeMemoryStore{
+		records: make(map[Kind]map[string]Entry),
+		locks:   make(map[string]time.Time),
+	}
+}
+
+func (c *AdmiteeMemoryStore) Get(kind Kind, namespace string, name string) (string, error) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.records[kind][namespace+"/"+name].Value, nil
+}
+
+func (c *AdmiteeMemoryStore) Set(kind Kind, namespace string, name string, value string) error {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.set(kind, namespace, name, value)
+	return nil
+}
+
+func (c *AdmiteeMemoryStore) SetNX(kind Kind, namespace string, name string, value string) (bool, error) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if _, ok := c.records[kind][namespace+"/"+name]; ok {
+		return false, nil
+	}
+	c.set(kind, namespace, name, value)
+	return true, nil
+}
+
+func (c *AdmiteeMemoryStore) set(kind Kind, namespace string, name string, value string) {
+	if c.records[kind] == nil {
+		c.records[kind] = make(map[string]Entry)
+	}
+	c.records[kind][namespace+"/"+name] = Entry{Namespace: namespace, Name: name, Value: value}
+}
+
+func (c *AdmiteeMemoryStore) Del(kind Kind, namespace string, name string) error {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	delete(c.records[kind], namespace+"/"+name)
+	return nil
+}
+
+func (c *AdmiteeMemoryStore) List(kind Kind, namespace string) ([]Entry, error) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	var entries []Entry
+	for _, entry := range c.records[kind] {
+		if namespace == "" || entry.Namespace == namespace {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Namespace+"/"+entries[i].Name < entries[j].Namespace+"/"+entries[j].Name
+	})
+	return entries, nil
+}
+
+func (c *AdmiteeMemoryStore) Lock(key string) (bool, error) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if expire, ok := c.locks[key]; ok && time.Now().Before(expire) {
+		return false, nil
+	}
+	c.locks[key] = time.Now().Add(10 * time.Second)
+	return true, nil
+}
+
+func (c *AdmiteeMemoryStore) UnLock(key string) int64 {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if _, ok := c.locks[key]; !ok {
+		return 0
+	}
+	delete(c.locks, key)
+	return 1
+}
+
+func (c *AdmiteeMemoryStore) Healthy() bool {
+	return true
+}
+
+func (c *AdmiteeMemoryStore) HealthCheck() {}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+)
+
+// PodRecordVersion schema version written by this build, legacy underscore values are version 0
+const PodRecordVersion = 1
+
+// PodRecord state of a pod in smoothing process, stored as KindPod
+type PodRecord struct {
+	Version     int                   `json:"version"`
+	Namespace   string                `json:"namespace"`
+	OwnerName   string                `json:"ownerName"` // direct owner, budgets are counted by it
+	TargetKind  string                `json:"targetKind,omitempty"`
+	TargetName  string                `json:"targetName,omitempty"`
+	SmoothName  string                `json:"smoothName,omitempty"` // Smooth applied, ClusterSmooth/name for a ClusterSmooth
+	Interval    int                   `json:"interval"`             // seconds between delete attempts
+	Timeout     int                   `json:"timeout"`              // hours before giving up, legacy records
+	Count       int                   `json:"count"`                // delete attempts by the smoothing loop
+	FirstSeen   time.Time             `json:"firstSeen"`
+	LastProbe   time.Time             `json:"lastProbe"`
+	RuleResults []v1alpha1.RuleResult `json:"ruleResults,omitempty"`
+	RequestedBy string                `json:"requestedBy,omitempty"` // user of the first delete request
+	// SmLabelValue value of smLabel before it was set smoothed, restored on abort
+	SmLabelValue string `json:"smLabelValue,omitempty"`
+	// TimeoutSeconds from FirstSeen before TimeoutPolicy applies, Timeout hours when not set
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+	TimeoutPolicy  string `json:"timeoutPolicy,omitempty"`
+	TimedOut       bool   `json:"timedOut,omitempty"` // timeout already reported
+}
+
+// TimeoutDuration time from FirstSeen before the timeout policy applies
+func (r *PodRecord) TimeoutDuration() time.Duration {
+	if r.TimeoutSeconds > 0 {
+		return time.Duration(r.TimeoutSeconds) * time.Second
+	}
+	return time.Duration(r.Timeout) * time.Hour
+}
+
+// ParsePodRecord decode a record, legacy values ns_owner_interval_timeout_lastime_count are upgraded
+func ParsePodRecord(value string) (*PodRecord, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		var record PodRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			return nil, err
+		}
+		if record.Version > PodRecordVersion {
+			return nil, fmt.Errorf("PodRecord version[%d] NOT SUPPORTED", record.Version)
+		}
+		record.Version = PodRecordVersion
+		return &record, nil
+	}
+	return parseLegacyPodRecord(value)
+}
+
+func parseLegacyPodRecord(value string) (*PodRecord, error) {
+	valueInfo := strings.Split(value, "_")
+	if len(valueInfo) < 6 {
+		return nil, fmt.Errorf("PodRecord legacy value[%s] invalid", value)
+	}
+	// namespace never contains "_", the last four fields are numbers
+	n := len(valueInfo)
+	interval, err := strconv.Atoi(valueInfo[n-4])
+	if err != nil {
+		return nil, fmt.Errorf("PodRecord legacy interval[%s] invalid", value)
+	}
+	timeout, err := strconv.Atoi(valueInfo[n-3])
+	if err != nil {
+		return nil, fmt.Errorf("PodRecord legacy timeout[%s] invalid", value)
+	}
+	lastime, err := strconv.ParseInt(valueInfo[n-2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("PodRecord legacy lastime[%s] invalid", value)
+	}
+	count, err := strconv.Atoi(valueInfo[n-1])
+	if err != nil {
+		return nil, fmt.Errorf("PodRecord legacy count[%s] invalid", value)
+	}
+
+	lastProbe := time.Unix(lastime, 0)
+	return &PodRecord{
+		Version:   PodRecordVersion,
+		Namespace: valueInfo[0],
+		OwnerName: strings.Join(valueInfo[1:n-4], "_"),
+		Interval:  interval,
+		Timeout:   timeout,
+		Count:     count,
+		// legacy values only kept the last attempt
+		FirstSeen: lastProbe.Add(-time.Duration(count*interval) * time.Second),
+		LastProbe: lastProbe,
+	}, nil
+}
+
+func (r *PodRecord) Encode() (string, error) {
+	r.Version = PodRecordVersion
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// GetPodRecord returns nil without error when the pod is not smoothing
+func GetPodRecord(store Store, namespace string, name string) (*PodRecord, error) {
+	value, err := store.Get(KindPod, namespace, name)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	return ParsePodRecord(value)
+}
+
+func SetPodRecord(store Store, namespace string, name string, record *PodRecord) error {
+	value, err := record.Encode()
+	if err != nil {
+		return err
+	}
+	return store.Set(KindPod, namespace, name, value)
+}
+
+func SetPodRecordNX(store Store, namespace string, name string, record *PodRecord) (bool, error) {
+	value, err := record.Encode()
+	if err != nil {
+		return false, err
+	}
+	return store.SetNX(KindPod, namespace, name, value)
+}
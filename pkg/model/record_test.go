@@ -0,0 +1,194 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePodRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *PodRecord
+		wantErr bool
+	}{
+		{
+			name:  "legacy value",
+			value: "default_web-7d9f_60_24_1700000000_3",
+			want: &PodRecord{
+				Version:   PodRecordVersion,
+				Namespace: "default",
+				OwnerName: "web-7d9f",
+				Interval:  60,
+				Timeout:   24,
+				Count:     3,
+				FirstSeen: time.Unix(1700000000-180, 0),
+				LastProbe: time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name:  "legacy value with underscores in owner",
+			value: "team-a_web_api_v2_30_1_1700000000_0",
+			want: &PodRecord{
+				Version:   PodRecordVersion,
+				Namespace: "team-a",
+				OwnerName: "web_api_v2",
+				Interval:  30,
+				Timeout:   1,
+				FirstSeen: time.Unix(1700000000, 0),
+				LastProbe: time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name:  "legacy value with surrounding spaces",
+			value: " default_web_60_24_1700000000_0\n",
+			want: &PodRecord{
+				Version:   PodRecordVersion,
+				Namespace: "default",
+				OwnerName: "web",
+				Interval:  60,
+				Timeout:   24,
+				FirstSeen: time.Unix(1700000000, 0),
+				LastProbe: time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name:    "legacy value too short",
+			value:   "default_web_60_24_1700000000",
+			wantErr: true,
+		},
+		{
+			name:    "legacy interval not a number",
+			value:   "default_web_sixty_24_1700000000_0",
+			wantErr: true,
+		},
+		{
+			name:    "legacy lastime not a number",
+			value:   "default_web_60_24_now_0",
+			wantErr: true,
+		},
+		{
+			name:  "versioned json",
+			value: `{"version":1,"namespace":"default","ownerName":"web_api","targetKind":"Deployment","targetName":"web","interval":60,"count":2,"firstSeen":"2023-11-14T22:13:20Z","lastProbe":"2023-11-14T22:15:20Z","timeoutSeconds":5400,"timeoutPolicy":"Deny"}`,
+			want: &PodRecord{
+				Version:        PodRecordVersion,
+				Namespace:      "default",
+				OwnerName:      "web_api",
+				TargetKind:     "Deployment",
+				TargetName:     "web",
+				Interval:       60,
+				Count:          2,
+				FirstSeen:      time.Unix(1700000000, 0),
+				LastProbe:      time.Unix(1700000120, 0),
+				TimeoutSeconds: 5400,
+				TimeoutPolicy:  "Deny",
+			},
+		},
+		{
+			name:  "json without version is upgraded",
+			value: `{"namespace":"default","ownerName":"web","interval":60,"firstSeen":"2023-11-14T22:13:20Z","lastProbe":"2023-11-14T22:13:20Z"}`,
+			want: &PodRecord{
+				Version:   PodRecordVersion,
+				Namespace: "default",
+				OwnerName: "web",
+				Interval:  60,
+				FirstSeen: time.Unix(1700000000, 0),
+				LastProbe: time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name:    "json from a newer version",
+			value:   `{"version":2,"namespace":"default"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			value:   `{"version":1,`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePodRecord(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePodRecord(%q) = %+v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePodRecord(%q): %v", tt.value, err)
+			}
+			assertPodRecord(t, got, tt.want)
+		})
+	}
+}
+
+func TestPodRecordRoundTrip(t *testing.T) {
+	// legacy values are written back as versioned json, names with underscores survive both ways
+	store := NewMemoryStore()
+	if err := store.Set(KindPod, "default", "web_api-0", "default_web_api_60_24_1700000000_2"); err != nil {
+		t.Fatal(err)
+	}
+	record, err := GetPodRecord(store, "default", "web_api-0")
+	if err != nil || record == nil {
+		t.Fatalf("GetPodRecord legacy: %+v, %v", record, err)
+	}
+	if record.OwnerName != "web_api" {
+		t.Errorf("OwnerName = %s, want web_api", record.OwnerName)
+	}
+
+	record.Count++
+	if err := SetPodRecord(store, "default", "web_api-0", record); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := store.Get(KindPod, "default", "web_api-0")
+	if value == "" || value[0] != '{' {
+		t.Fatalf("record written as %q, want versioned json", value)
+	}
+	again, err := GetPodRecord(store, "default", "web_api-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPodRecord(t, again, record)
+
+	if ok, err := SetPodRecordNX(store, "default", "web_api-0", &PodRecord{}); err != nil || ok {
+		t.Errorf("SetPodRecordNX over an existing record = %v, %v, want false", ok, err)
+	}
+	if missing, err := GetPodRecord(store, "default", "missing"); err != nil || missing != nil {
+		t.Errorf("GetPodRecord missing = %+v, %v, want nil", missing, err)
+	}
+}
+
+func TestPodRecordTimeoutDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		record PodRecord
+		want   time.Duration
+	}{
+		{name: "legacy hours", record: PodRecord{Timeout: 24}, want: 24 * time.Hour},
+		{name: "seconds win over hours", record: PodRecord{Timeout: 24, TimeoutSeconds: 5400}, want: 90 * time.Minute},
+		{name: "not set", record: PodRecord{}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.record.TimeoutDuration(); got != tt.want {
+				t.Errorf("TimeoutDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertPodRecord(t *testing.T, got *PodRecord, want *PodRecord) {
+	t.Helper()
+	if !got.FirstSeen.Equal(want.FirstSeen) || !got.LastProbe.Equal(want.LastProbe) {
+		t.Errorf("times = %v/%v, want %v/%v", got.FirstSeen, got.LastProbe, want.FirstSeen, want.LastProbe)
+	}
+	gotCopy, wantCopy := *got, *want
+	gotCopy.FirstSeen, gotCopy.LastProbe = want.FirstSeen, want.LastProbe
+	if !reflect.DeepEqual(gotCopy, wantCopy) {
+		t.Errorf("record = %+v, want %+v", gotCopy, wantCopy)
+	}
+}
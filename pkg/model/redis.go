@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/golang/glog"
 )
 
+const redisKeyPrefix = "ADMITEE_SMOOTH_"
+
 type AdmiteeRedisClient struct {
 	Client *redis.Client
 	Ctx    context.Context
@@ -16,14 +19,67 @@ type AdmiteeRedisClient struct {
 	sync.Mutex
 }
 
-func (c *AdmiteeRedisClient) Lock(key string) bool {
+// redisKey ADMITEE_SMOOTH_<kind>_<namespace>_<name>
+func redisKey(kind Kind, namespace string, name string) string {
+	return redisKeyPrefix + string(kind) + "_" + namespace + "_" + name
+}
+
+func (c *AdmiteeRedisClient) Get(kind Kind, namespace string, name string) (string, error) {
+	value, err := c.Client.Get(c.Ctx, redisKey(kind, namespace, name)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+func (c *AdmiteeRedisClient) Set(kind Kind, namespace string, name string, value string) error {
+	return c.Client.Set(c.Ctx, redisKey(kind, namespace, name), value, 0).Err()
+}
+
+func (c *AdmiteeRedisClient) SetNX(kind Kind, namespace string, name string, value string) (bool, error) {
+	return c.Client.SetNX(c.Ctx, redisKey(kind, namespace, name), value, 0).Result()
+}
+
+func (c *AdmiteeRedisClient) Del(kind Kind, namespace string, name string) error {
+	return c.Client.Del(c.Ctx, redisKey(kind, namespace, name)).Err()
+}
+
+func (c *AdmiteeRedisClient) List(kind Kind, namespace string) ([]Entry, error) {
+	prefix := redisKeyPrefix + string(kind) + "_"
+	match := prefix + "*"
+	if namespace != "" {
+		match = prefix + namespace + "_*"
+	}
+
+	var entries []Entry
+	// SCAN instead of KEYS, never block redis
+	iter := c.Client.Scan(c.Ctx, 0, match, 100).Iterator()
+	for iter.Next(c.Ctx) {
+		key := iter.Val()
+		// namespace never contains "_"
+		keyInfo := strings.SplitN(strings.TrimPrefix(key, prefix), "_", 2)
+		if len(keyInfo) != 2 {
+			continue
+		}
+		value, err := c.Client.Get(c.Ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, Entry{Namespace: keyInfo[0], Name: keyInfo[1], Value: value})
+	}
+	return entries, iter.Err()
+}
+
+func (c *AdmiteeRedisClient) Lock(key string) (bool, error) {
 	c.Mutex.Lock()
 	defer c.Mutex.Unlock()
 	bool, err := c.Client.SetNX(c.Ctx, key, 1, 10*time.Second).Result()
 	if err != nil {
 		glog.Errorf("FAILURE: Lock[%v]", err)
 	}
-	return bool
+	return bool, err
 }
 
 func (c *AdmiteeRedisClient) UnLock(key string) int64 {
@@ -34,7 +90,11 @@ func (c *AdmiteeRedisClient) UnLock(key string) int64 {
 	return nums
 }
 
-func (c *AdmiteeRedisClient) HealthCheckRdb() {
+func (c *AdmiteeRedisClient) Healthy() bool {
+	return c.Health
+}
+
+func (c *AdmiteeRedisClient) HealthCheck() {
 	for {
 		_, err := c.Client.Ping(c.Ctx).Result()
 		if err != nil {
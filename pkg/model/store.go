@@ -0,0 +1,40 @@
+package model
+
+// Kind groups the records admitee keeps per pod
+type Kind string
+
+const (
+	KindPod      Kind = "POD"      // pods in smoothing process, value is a PodRecord
+	KindDelete   Kind = "DEL"      // pods whose delete has been allowed
+	KindLabel    Kind = "LABEL"    // Smooth config cached once smLabel is applied
+	KindNotReady Kind = "NOTREADY" // pods allowed once, avoid network recycle of Terminating pod
+)
+
+// Kinds all record kinds, used by cleanup of orphan records
+var Kinds = []Kind{KindPod, KindDelete, KindLabel, KindNotReady}
+
+type Entry struct {
+	Namespace string
+	Name      string
+	Value     string
+}
+
+// Store keeps the smoothing state shared by admitee replicas
+type Store interface {
+	// Get returns an empty value without error when the record does not exist
+	Get(kind Kind, namespace string, name string) (string, error)
+	Set(kind Kind, namespace string, name string, value string) error
+	// SetNX only sets the record when it does not exist yet
+	SetNX(kind Kind, namespace string, name string, value string) (bool, error)
+	Del(kind Kind, namespace string, name string) error
+	// List records of kind, namespace "" for all namespaces
+	List(kind Kind, namespace string) ([]Entry, error)
+
+	// Lock is a short-lived mutex shared by replicas, false without error when it is held by someone else
+	Lock(key string) (bool, error)
+	UnLock(key string) int64
+
+	Healthy() bool
+	// HealthCheck refresh Healthy until the process exits
+	HealthCheck()
+}
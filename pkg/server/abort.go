@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/server/smooth"
+
+	"github.com/golang/glog"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// abortTimeout keeps an abort under the WriteTimeout of the server so its response is delivered
+const abortTimeout = 8 * time.Second
+
+// Abort cancel smoothing through POST /abort/smooth?namespace=ns&pod=name, or &smooth=name for every pod of a Smooth
+func (s *apiServer) Abort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, WResponse{Status: "failure", Message: "method " + r.Method + " not allowed, expect POST"})
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	namePod := r.URL.Query().Get("pod")
+	nameSmooth := r.URL.Query().Get("smooth")
+	if namespace == "" || (namePod == "") == (nameSmooth == "") {
+		writeResponse(w, http.StatusBadRequest, WResponse{Status: "failure", Message: "namespace and one of pod or smooth are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), abortTimeout)
+	defer cancel()
+
+	// callers need the same right as aborting by annotation: patch the pod or the Smooth
+	user, status, err := s.authorizeAbort(ctx, r, namespace, namePod, nameSmooth)
+	if err != nil {
+		glog.Errorf("FAILURE: Abort Authorize[%s]: %v", r.RemoteAddr, err)
+		writeResponse(w, status, WResponse{Status: "failure", Message: err.Error()})
+		return
+	}
+
+	var sm = &smooth.SmoothManager{
+		Store:         s.store,
+		ClientSmooth:  s.clientSmooth,
+		ClientKubeSet: s.clientKubeSet,
+		Ctx:           ctx,
+		Recorder:      s.recorder,
+	}
+	abortedBy := "api " + user
+
+	var aborted []string
+	if namePod != "" {
+		pod, err := s.clientKubeSet.CoreV1().Pods(namespace).Get(sm.Ctx, namePod, metav1.GetOptions{})
+		if err != nil {
+			glog.Errorf("FAILURE: Abort POD[%s/%s]: %v", namespace, namePod, err)
+			writeResponse(w, http.StatusNotFound, WResponse{Status: "failure", Message: err.Error()})
+			return
+		}
+		if err := sm.AbortSmoothing(*pod, abortedBy); errors.Is(err, smooth.ErrNotSmoothing) {
+			writeResponse(w, http.StatusNotFound, WResponse{Status: "failure", Message: err.Error()})
+			return
+		} else if err != nil {
+			glog.Errorf("FAILURE: Abort POD[%s/%s]: %v", namespace, namePod, err)
+			writeResponse(w, http.StatusInternalServerError, WResponse{Status: "failure", Message: err.Error()})
+			return
+		}
+		aborted = append(aborted, namePod)
+	} else {
+		smConfig, err := sm.GetSmooth(namespace, nameSmooth)
+		if err != nil {
+			glog.Errorf("FAILURE: Abort Smooth[%s/%s]: %v", namespace, nameSmooth, err)
+			writeResponse(w, http.StatusNotFound, WResponse{Status: "failure", Message: err.Error()})
+			return
+		}
+		aborted, err = sm.AbortSmooth(smConfig, abortedBy)
+		if err != nil {
+			glog.Errorf("FAILURE: Abort Smooth[%s/%s]: %v", namespace, nameSmooth, err)
+			writeResponse(w, http.StatusInternalServerError, WResponse{Status: "failure", Message: err.Error()})
+			return
+		}
+	}
+	writeResponse(w, http.StatusOK, WResponse{Status: "aborted", Message: strings.Join(aborted, ",")})
+}
+
+// authorizeAbort authenticate the bearer token by TokenReview, then check patch on the pod or Smooth by SubjectAccessReview
+func (s *apiServer) authorizeAbort(ctx context.Context, r *http.Request, namespace string, namePod string, nameSmooth string) (string, int, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return "", http.StatusUnauthorized, fmt.Errorf("bearer token required")
+	}
+	review, err := s.clientKubeSet.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("TokenReview: %v", err)
+	}
+	if !review.Status.Authenticated {
+		return "", http.StatusUnauthorized, fmt.Errorf("token not authenticated %s", review.Status.Error)
+	}
+
+	userInfo := review.Status.User
+	attributes := &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "patch", Resource: "pods", Name: namePod}
+	if nameSmooth != "" {
+		attributes = &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "patch", Group: v1alpha1.Group, Resource: v1alpha1.Resource, Name: nameSmooth}
+	}
+	extra := make(map[string]authorizationv1.ExtraValue, len(userInfo.Extra))
+	for key, value := range userInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	sar, err := s.clientKubeSet.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               userInfo.Username,
+			UID:                userInfo.UID,
+			Groups:             userInfo.Groups,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("SubjectAccessReview: %v", err)
+	}
+	if !sar.Status.Allowed {
+		return "", http.StatusForbidden, fmt.Errorf("user %s can not patch %s %s/%s %s", userInfo.Username, attributes.Resource, namespace, attributes.Name, sar.Status.Reason)
+	}
+	return userInfo.Username, http.StatusOK, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp WResponse) {
+	data, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	w.Write(data)
+}
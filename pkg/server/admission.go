@@ -10,10 +10,13 @@ import (
 	"admitee/pkg/server/smooth"
 
 	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 )
 
 var (
@@ -46,61 +49,175 @@ func (s *apiServer) Admission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var admissionResponse *v1beta1.AdmissionResponse
-	ar := v1beta1.AdmissionReview{}
+	// answer in the same version the apiserver asked with, v1 by default
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(body, &typeMeta); err != nil {
+		glog.Errorf("Can't decode body: %v", err)
+	}
+
+	var admissionReview interface{}
+	switch typeMeta.APIVersion {
+	case v1beta1.SchemeGroupVersion.String():
+		admissionReview = s.admissionReviewV1beta1(r.URL.Path, body)
+	default:
+		admissionReview = s.admissionReviewV1(r.URL.Path, body)
+	}
+
+	resp, err := json.Marshal(admissionReview)
+	if err != nil {
+		glog.Errorf("Can't encode response: %v", err)
+		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+	}
+	//	glog.Infof("Ready to write reponse ...")
+	if _, err := w.Write(resp); err != nil {
+		glog.Errorf("Can't write response: %v", err)
+		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (s *apiServer) admissionReviewV1(url string, body []byte) *admissionv1.AdmissionReview {
+	var admissionResponse *admissionv1.AdmissionResponse
+	ar := admissionv1.AdmissionReview{}
 	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
 		glog.Errorf("Can't decode body: %v", err)
-		admissionResponse = &v1beta1.AdmissionResponse{
+		admissionResponse = &admissionv1.AdmissionResponse{
 			Result: &metav1.Status{
 				Message: err.Error(),
 			},
 		}
-	} else {
-		admissionResponse = s.ReturnAdmissionResponse(r.URL.Path, &ar)
+	} else if ar.Request != nil {
+		admissionResponse = s.ReturnAdmissionResponse(url, ar.Request)
 	}
 
-	admissionReview := v1beta1.AdmissionReview{}
+	admissionReview := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+	}
 	if admissionResponse != nil {
 		admissionReview.Response = admissionResponse
 		if ar.Request != nil {
 			admissionReview.Response.UID = ar.Request.UID
 		}
 	}
+	return &admissionReview
+}
 
-	resp, err := json.Marshal(admissionReview)
-	if err != nil {
-		glog.Errorf("Can't encode response: %v", err)
-		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+func (s *apiServer) admissionReviewV1beta1(url string, body []byte) *v1beta1.AdmissionReview {
+	var admissionResponse *v1beta1.AdmissionResponse
+	ar := v1beta1.AdmissionReview{}
+	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+		glog.Errorf("Can't decode body: %v", err)
+		admissionResponse = &v1beta1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	} else if ar.Request != nil {
+		admissionResponse = convertAdmissionResponseToV1beta1(s.ReturnAdmissionResponse(url, convertAdmissionRequestToV1(ar.Request)))
 	}
-	//	glog.Infof("Ready to write reponse ...")
-	if _, err := w.Write(resp); err != nil {
-		glog.Errorf("Can't write response: %v", err)
-		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
+
+	admissionReview := v1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1beta1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
 	}
+	if admissionResponse != nil {
+		admissionReview.Response = admissionResponse
+		if ar.Request != nil {
+			admissionReview.Response.UID = ar.Request.UID
+		}
+	}
+	return &admissionReview
 }
 
-func (s *apiServer) ReturnAdmissionResponse(url string, ar *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
-	var admissionResp *v1beta1.AdmissionResponse
+func (s *apiServer) ReturnAdmissionResponse(url string, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	var admissionResp *admissionv1.AdmissionResponse
 	switch url {
 	case "/admission/smooth":
 		var sm = &smooth.SmoothManager{
-			ClientRedis:   s.clientRedis,
+			Store:         s.store,
 			ClientSmooth:  s.clientSmooth,
 			ClientKubeSet: s.clientKubeSet,
 			Ctx:           context.Background(),
+			Recorder:      s.recorder,
+			Deadline:      s.config.AdmissionDeadline,
+			Evaluation:    s.config.RuleEvaluation,
+			FailurePolicy: s.config.FailurePolicy,
+			LockTimeout:   s.config.LockTimeout,
 		}
-		return sm.EnterSmoothProcess(ar)
+		return sm.EnterSmoothProcess(req)
+	case "/mutate/smooth":
+		var sm = &smooth.SmoothManager{
+			Store:         s.store,
+			ClientSmooth:  s.clientSmooth,
+			ClientKubeSet: s.clientKubeSet,
+			Ctx:           context.Background(),
+		}
+		return sm.EnterMutateProcess(req)
 	}
 	return admissionResp
 }
 
-func (s *apiServer) DeamonSmooth() {
+func (s *apiServer) DeamonSmooth(ctx context.Context) {
 	var sm = &smooth.SmoothManager{
 		ClientKubeSet: s.clientKubeSet,
-		ClientRedis:   s.clientRedis,
+		Store:         s.store,
+		ClientSmooth:  s.clientSmooth,
 		Ctx:           context.Background(),
+		Recorder:      s.recorder,
+		Evaluation:    s.config.RuleEvaluation,
+	}
+	// informers live as long as the leadership, a new leader starts from fresh caches
+	go s.runLeaderElection(ctx, func(ctx context.Context) {
+		kubeInformers := informers.NewSharedInformerFactory(s.clientKubeSet, 0)
+		smoothInformers := dynamicinformer.NewDynamicSharedInformerFactory(s.clientSmooth, 0)
+		controller := smooth.NewController(sm, kubeInformers, smoothInformers)
+		kubeInformers.Start(ctx.Done())
+		smoothInformers.Start(ctx.Done())
+		controller.Run(ctx)
+	})
+}
+
+// convertAdmissionRequestToV1 v1beta1 and v1 requests share the same fields
+func convertAdmissionRequestToV1(req *v1beta1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:                req.UID,
+		Kind:               req.Kind,
+		Resource:           req.Resource,
+		SubResource:        req.SubResource,
+		RequestKind:        req.RequestKind,
+		RequestResource:    req.RequestResource,
+		RequestSubResource: req.RequestSubResource,
+		Name:               req.Name,
+		Namespace:          req.Namespace,
+		Operation:          admissionv1.Operation(req.Operation),
+		UserInfo:           req.UserInfo,
+		Object:             req.Object,
+		OldObject:          req.OldObject,
+		DryRun:             req.DryRun,
+		Options:            req.Options,
+	}
+}
+
+func convertAdmissionResponseToV1beta1(resp *admissionv1.AdmissionResponse) *v1beta1.AdmissionResponse {
+	if resp == nil {
+		return nil
+	}
+	var patchType *v1beta1.PatchType
+	if resp.PatchType != nil {
+		pt := v1beta1.PatchType(*resp.PatchType)
+		patchType = &pt
+	}
+	return &v1beta1.AdmissionResponse{
+		UID:              resp.UID,
+		Allowed:          resp.Allowed,
+		Result:           resp.Result,
+		Patch:            resp.Patch,
+		PatchType:        patchType,
+		AuditAnnotations: resp.AuditAnnotations,
+		Warnings:         resp.Warnings,
 	}
-	go sm.LoopSmooth()
-	go sm.LoopDelete()
-	go sm.LoopKClear()
 }
@@ -1,10 +1,35 @@
 package config
 
+import "time"
+
 type Config struct {
 	BindAddress string `json:"bindAddress"`
 	BindPort    int    `json:"bindPort"`
 	TlsCert     string `json:"tlsCert"`
 	TlsKey      string `json:"tlsKey"`
+
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+
+	// AdmissionDeadline bounds rule probing of an admission request
+	AdmissionDeadline time.Duration `json:"admissionDeadline"`
+	// RuleEvaluation default evaluation of Smooths, sync or async
+	RuleEvaluation string `json:"ruleEvaluation"`
+	// EnableAbortAPI serve POST /abort/smooth, callers are checked by TokenReview and SubjectAccessReview
+	EnableAbortAPI bool `json:"enableAbortAPI"`
+	// FailurePolicy default answer while the store or apiserver is unreachable, Fail or Ignore
+	FailurePolicy string `json:"failurePolicy"`
+	// LockTimeout bounds the wait for the lock of a target
+	LockTimeout time.Duration `json:"lockTimeout"`
+}
+
+// LeaderElectionConfig only the leader runs the smoothing controller, every replica serves admission
+type LeaderElectionConfig struct {
+	LeaderElect   bool          `json:"leaderElect"`
+	Namespace     string        `json:"namespace"`
+	Name          string        `json:"name"`
+	LeaseDuration time.Duration `json:"leaseDuration"`
+	RenewDeadline time.Duration `json:"renewDeadline"`
+	RetryPeriod   time.Duration `json:"retryPeriod"`
 }
 
 func NewServerConfig() *Config {
@@ -15,10 +15,10 @@ type WResponse struct {
 func (s *apiServer) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	var status = http.StatusOK
 	var data []byte
-	if !s.clientRedis.Health {
+	if !s.store.Healthy() {
 		data, _ = json.Marshal(WResponse{Status: "down"})
 		status = http.StatusServiceUnavailable
-		glog.Errorf("FAILURE: Redis unhealth[%v]", s.clientRedis.Health)
+		glog.Errorf("FAILURE: Store unhealth[%v]", s.store.Healthy())
 	} else {
 		data, _ = json.Marshal(WResponse{Status: "up"})
 	}
@@ -29,5 +29,5 @@ func (s *apiServer) HealthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *apiServer) DeamonHealthCheck() {
-	go s.clientRedis.HealthCheckRdb()
+	go s.store.HealthCheck()
 }
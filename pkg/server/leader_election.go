@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// runLeaderElection runs run while this replica holds the Lease, and campaigns again after losing it
+func (s *apiServer) runLeaderElection(ctx context.Context, run func(ctx context.Context)) {
+	cfg := s.config.LeaderElection
+	if !cfg.LeaderElect {
+		run(ctx)
+		return
+	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		glog.Fatalf("FAILURE: Get Hostname[%v]", err)
+	}
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		cfg.Namespace,
+		cfg.Name,
+		s.clientKubeSet.CoreV1(),
+		s.clientKubeSet.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+	if err != nil {
+		glog.Fatalf("FAILURE: New Lease Lock[%v]", err)
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			Name:            cfg.Name,
+			LeaseDuration:   cfg.LeaseDuration,
+			RenewDeadline:   cfg.RenewDeadline,
+			RetryPeriod:     cfg.RetryPeriod,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					glog.Infof("MESSAGE: Leader[%s/%s] Started Leading[%s]", cfg.Namespace, cfg.Name, identity)
+					run(ctx)
+				},
+				OnStoppedLeading: func() {
+					glog.Infof("MESSAGE: Leader[%s/%s] Stopped Leading[%s]", cfg.Namespace, cfg.Name, identity)
+				},
+				OnNewLeader: func(leader string) {
+					if leader != identity {
+						glog.Infof("MESSAGE: Leader[%s/%s] New Leader[%s]", cfg.Namespace, cfg.Name, leader)
+					}
+				},
+			},
+		})
+	}, time.Second)
+}
@@ -1,9 +1,13 @@
 package options
 
 import (
+	"admitee/pkg/api/v1alpha1"
 	"admitee/pkg/server/config"
 	"fmt"
 	"github.com/spf13/pflag"
+	"os"
+	"strings"
+	"time"
 )
 
 // Options used for admitee server
@@ -16,6 +20,22 @@ type Options struct {
 	RedisPort     int
 	RedisDB       int
 	RedisPassword string
+
+	Store          string
+	StoreNamespace string
+
+	LeaderElect              bool
+	LeaderElectNamespace     string
+	LeaderElectName          string
+	LeaderElectLeaseDuration time.Duration
+	LeaderElectRenewDeadline time.Duration
+	LeaderElectRetryPeriod   time.Duration
+
+	AdmissionDeadline time.Duration
+	RuleEvaluation    string
+	EnableAbortAPI    bool
+	FailurePolicy     string
+	LockTimeout       time.Duration
 }
 
 func NewOptions() *Options {
@@ -27,6 +47,19 @@ func (o *Options) ApplyTo(cfg *config.Config) error {
 	cfg.BindPort = o.BindPort
 	cfg.TlsCert = o.TlsCert
 	cfg.TlsKey = o.TlsKey
+	cfg.LeaderElection = config.LeaderElectionConfig{
+		LeaderElect:   o.LeaderElect,
+		Namespace:     o.LeaderElectNamespace,
+		Name:          o.LeaderElectName,
+		LeaseDuration: o.LeaderElectLeaseDuration,
+		RenewDeadline: o.LeaderElectRenewDeadline,
+		RetryPeriod:   o.LeaderElectRetryPeriod,
+	}
+	cfg.AdmissionDeadline = o.AdmissionDeadline
+	cfg.RuleEvaluation = o.RuleEvaluation
+	cfg.EnableAbortAPI = o.EnableAbortAPI
+	cfg.FailurePolicy = failurePolicy(o.FailurePolicy)
+	cfg.LockTimeout = o.LockTimeout
 
 	return nil
 }
@@ -44,9 +77,50 @@ func (o *Options) Validate() []error {
 		)
 	}
 
+	switch o.Store {
+	case StoreRedis, StoreMemory, StoreKubernetes:
+	default:
+		errors = append(errors, fmt.Errorf("--store %v must be one of redis, memory or kubernetes", o.Store))
+	}
+	if o.Store == StoreKubernetes && o.StoreNamespace == "" {
+		errors = append(errors, fmt.Errorf("--store-namespace must be set for kubernetes store"))
+	}
+
+	if o.LeaderElect {
+		if o.LeaderElectNamespace == "" {
+			errors = append(errors, fmt.Errorf("--leader-elect-namespace must be set when --leader-elect is true"))
+		}
+		if o.LeaderElectLeaseDuration <= o.LeaderElectRenewDeadline {
+			errors = append(errors, fmt.Errorf("--leader-elect-lease-duration must be greater than --leader-elect-renew-deadline"))
+		}
+	}
+
+	if o.AdmissionDeadline < 0 {
+		errors = append(errors, fmt.Errorf("--admission-deadline %v must not be negative", o.AdmissionDeadline))
+	}
+	if !strings.EqualFold(o.RuleEvaluation, v1alpha1.EvaluationSync) && !strings.EqualFold(o.RuleEvaluation, v1alpha1.EvaluationAsync) {
+		errors = append(errors, fmt.Errorf("--rule-evaluation %v must be one of sync or async", o.RuleEvaluation))
+	}
+	if !strings.EqualFold(o.FailurePolicy, v1alpha1.FailurePolicyFail) && !strings.EqualFold(o.FailurePolicy, v1alpha1.FailurePolicyIgnore) {
+		errors = append(errors, fmt.Errorf("--failure-policy %v must be one of Fail or Ignore", o.FailurePolicy))
+	}
+	if o.LockTimeout < 0 {
+		errors = append(errors, fmt.Errorf("--lock-timeout %v must not be negative", o.LockTimeout))
+	}
+
 	return errors
 }
 
+// failurePolicy --failure-policy in the case of failurePolicy of Smooths, fail becomes Fail
+func failurePolicy(value string) string {
+	for _, policy := range []string{v1alpha1.FailurePolicyFail, v1alpha1.FailurePolicyIgnore} {
+		if strings.EqualFold(value, policy) {
+			return policy
+		}
+	}
+	return value
+}
+
 // AddFlags adds flags related to features for a specific server option to the
 // specified FlagSet.
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
@@ -66,4 +140,35 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&o.RedisPort, "redis-port", 6379, "Redis port.")
 	fs.IntVar(&o.RedisDB, "redis-db", 0, "Redis db number.")
 	fs.StringVar(&o.RedisPassword, "redis-password", "test", "Redis password.")
+
+	fs.StringVar(&o.Store, "store", StoreRedis, ""+
+		"Where replicas share smoothing state: redis, memory (single replica only) or kubernetes (ConfigMaps and Leases).")
+	fs.StringVar(&o.StoreNamespace, "store-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of ConfigMaps and Leases for the kubernetes store, default $POD_NAMESPACE.")
+
+	fs.BoolVar(&o.LeaderElect, "leader-elect", true, ""+
+		"Elect a leader through a coordination.k8s.io Lease so only one replica runs the smoothing controller.")
+	fs.StringVar(&o.LeaderElectNamespace, "leader-elect-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of the leader election Lease, default $POD_NAMESPACE.")
+	fs.StringVar(&o.LeaderElectName, "leader-elect-name", "admiteed", "Name of the leader election Lease.")
+	fs.DurationVar(&o.LeaderElectLeaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"Duration non-leader replicas wait before trying to take over leadership.")
+	fs.DurationVar(&o.LeaderElectRenewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"Duration the leader retries refreshing leadership before giving it up.")
+	fs.DurationVar(&o.LeaderElectRetryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"Duration between leader election attempts.")
+
+	fs.DurationVar(&o.AdmissionDeadline, "admission-deadline", 8*time.Second, ""+
+		"Time an admission request may spend on rules, keep it under timeoutSeconds of the webhook. 0 for no deadline.")
+	fs.StringVar(&o.RuleEvaluation, "rule-evaluation", "sync", ""+
+		"Default evaluation of Smooths: sync probes rules inside the admission request, "+
+		"async answers from results probed in background. spec.evaluation of a Smooth overrides it.")
+	fs.BoolVar(&o.EnableAbortAPI, "enable-abort-api", false, ""+
+		"Serve POST /abort/smooth?namespace=&pod= (or &smooth=) to abort smoothing. "+
+		"Callers authenticate with a bearer token and need patch on the pod or Smooth.")
+	fs.StringVar(&o.FailurePolicy, "failure-policy", v1alpha1.FailurePolicyFail, ""+
+		"Default answer while the state store or apiserver is unreachable: Fail denies the delete, "+
+		"Ignore allows it without smoothing. spec.failurePolicy of a Smooth overrides it.")
+	fs.DurationVar(&o.LockTimeout, "lock-timeout", 5*time.Second, ""+
+		"Time an admission request waits for the lock of its target before it is denied, keep it under --admission-deadline. 0 for no limit.")
 }
@@ -0,0 +1,41 @@
+package options
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"admitee/pkg/model"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StoreRedis      = "redis"
+	StoreMemory     = "memory"
+	StoreKubernetes = "kubernetes"
+)
+
+// NewStore creates the state store selected by --store
+func (opt *Options) NewStore(clientKubeSet kubernetes.Interface) (model.Store, error) {
+	switch opt.Store {
+	case StoreRedis:
+		return opt.NewClientRedis()
+	case StoreMemory:
+		return model.NewMemoryStore(), nil
+	case StoreKubernetes:
+		identity, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		store := &model.AdmiteeKubeStore{
+			Client:    clientKubeSet,
+			Ctx:       context.Background(),
+			Namespace: opt.StoreNamespace,
+			Identity:  identity,
+		}
+		store.Health.Store(true)
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown store %q", opt.Store)
+}
@@ -8,29 +8,34 @@ import (
 	"strconv"
 	"time"
 
+	"admitee/pkg/metrics"
 	"admitee/pkg/model"
 	"admitee/pkg/server/config"
+	"admitee/pkg/server/smooth"
 
 	"github.com/golang/glog"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 )
 
 type apiServer struct {
 	config        *config.Config
-	clientRedis   *model.AdmiteeRedisClient
+	store         model.Store
 	clientSmooth  dynamic.Interface
 	clientKubeSet *kubernetes.Clientset
+	recorder      record.EventRecorder
 	Server        *http.Server
 	stopCh        chan struct{}
 }
 
-func NewServer(cfg *config.Config, clientSmooth dynamic.Interface, clientKubeSet *kubernetes.Clientset, clientRedis *model.AdmiteeRedisClient) (*apiServer, error) {
+func NewServer(cfg *config.Config, clientSmooth dynamic.Interface, clientKubeSet *kubernetes.Clientset, store model.Store) (*apiServer, error) {
 	server := &apiServer{
 		config:        cfg,
-		clientRedis:   clientRedis,
+		store:         store,
 		clientSmooth:  clientSmooth,
 		clientKubeSet: clientKubeSet,
+		recorder:      smooth.NewEventRecorder(clientKubeSet),
 	}
 
 	return server, nil
@@ -44,7 +49,7 @@ func (s *apiServer) Run(ctx context.Context) {
 		glog.Errorf("FAILURE: Failed to load key pair: %v", err)
 	}
 
-	s.DeamonSmooth()
+	s.DeamonSmooth(ctx)
 	s.DeamonHealthCheck()
 
 	go func() {
@@ -58,9 +63,13 @@ func (s *apiServer) Run(ctx context.Context) {
 		// define http server and server handler
 		mux := http.NewServeMux()
 
-		// mux.HandleFunc("/mutate", whsvr.serve)
 		mux.HandleFunc("/admission/smooth", s.Admission)
+		mux.HandleFunc("/mutate/smooth", s.Admission)
 		mux.HandleFunc("/healthz", s.HealthCheck)
+		mux.Handle("/metrics", metrics.Handler())
+		if s.config.EnableAbortAPI {
+			mux.HandleFunc("/abort/smooth", s.Abort)
+		}
 		s.Server.Handler = mux
 
 		glog.Infof("Start to listening on http address: %s", s.Server.Addr)
@@ -0,0 +1,157 @@
+package smooth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrNotSmoothing the pod has no smoothing record, nothing to abort
+var ErrNotSmoothing = fmt.Errorf("pod not in smoothing")
+
+// AbortSmoothing cancel the smoothing of pod: run restore rules, restore smLabel and
+// the readiness gate, then clear every record of the pod
+func (sm *SmoothManager) AbortSmoothing(pod corev1.Pod, abortedBy string) error {
+	record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrNotSmoothing
+	}
+	smConfig, _, err := sm.PodSmoothConfig(pod)
+	if err != nil {
+		return err
+	}
+
+	var reasons []string
+	if smConfig != nil {
+		reasons = append(reasons, sm.RestoreRules(pod, smConfig)...)
+	}
+	if err := sm.RestoreTraffic(pod, smConfig, record, GateReasonAborted, "Smoothing aborted by "+abortedBy); err != nil {
+		return err
+	}
+
+	if err := sm.ClearPodState(pod.Namespace, pod.Name); err != nil {
+		return err
+	}
+
+	sm.Eventf(pod, record.SmoothName, corev1.EventTypeNormal, EventSmoothAborted, "Smoothing aborted by %s %s", abortedBy, strings.Join(reasons, ","))
+	glog.Infof("SUCCESS: Abort POD[%s/%s] By[%s] Restore[%s]", pod.Namespace, pod.Name, abortedBy, strings.Join(reasons, ","))
+	return nil
+}
+
+// RestoreTraffic restore smLabel to its value before smoothing (or remove it) and set the readiness gate True
+func (sm *SmoothManager) RestoreTraffic(pod corev1.Pod, smConfig *v1alpha1.Smooth, record *model.PodRecord, reason string, message string) error {
+	if smConfig != nil && smConfig.Spec.SmLabel != "" && pod.Labels[smConfig.Spec.SmLabel] == "smoothed" {
+		// the previous value lets the owner adopt the pod again, without it the label is removed
+		var value interface{}
+		if record != nil && record.SmLabelValue != "" {
+			value = record.SmLabelValue
+		}
+		data, _ := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{smConfig.Spec.SmLabel: value}}})
+		if _, err := sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Patch(sm.Ctx, pod.Name, types.StrategicMergePatchType, data, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("smLabel restore[%s/%s]: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	return sm.SetReadinessGate(pod, corev1.ConditionTrue, reason, message)
+}
+
+// AbortSmooth abort every pod smoothing by the Smooth, returns the pods aborted
+func (sm *SmoothManager) AbortSmooth(smooth *v1alpha1.Smooth, abortedBy string) ([]string, error) {
+	entries, err := sm.Store.List(model.KindPod, smooth.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var aborted []string
+	for _, entry := range entries {
+		record, err := model.ParsePodRecord(entry.Value)
+		if err != nil {
+			continue
+		}
+		if record.SmoothName != "" {
+			if record.SmoothName != smooth.Name {
+				continue
+			}
+		} else if record.TargetKind != smooth.Spec.TargetRef.Kind || record.TargetName != smooth.Spec.TargetRef.Name {
+			continue
+		}
+
+		pod, err := sm.ClientKubeSet.CoreV1().Pods(entry.Namespace).Get(sm.Ctx, entry.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			if err := sm.ClearPodState(entry.Namespace, entry.Name); err != nil {
+				return aborted, err
+			}
+			continue
+		} else if err != nil {
+			return aborted, err
+		}
+		if err := sm.AbortSmoothing(*pod, abortedBy); err == ErrNotSmoothing {
+			// finished while the list was walked
+			continue
+		} else if err != nil {
+			return aborted, err
+		}
+		aborted = append(aborted, entry.Name)
+	}
+	return aborted, nil
+}
+
+// RestoreRules request the restore rules of smConfig in order, failures are reported and do not stop the others
+func (sm *SmoothManager) RestoreRules(pod corev1.Pod, smConfig *v1alpha1.Smooth) []string {
+	var reasons []string
+	var vars = sm.NewRuleVars(pod)
+	for _, rule := range smConfig.Spec.RestoreRules {
+		rule, method, url, tlsConfig, err := sm.PrepareRule(pod, smConfig, rule, vars)
+		if err != nil {
+			reasons = append(reasons, "{"+err.Error()+"}")
+			continue
+		}
+		resp, err := ProbeRule(sm.Ctx, method, url, rule, tlsConfig)
+		if err != nil {
+			glog.Errorf("FAILURE: POD[%s/%s] Restore[%s %s]: %v", pod.Namespace, pod.Name, rule.Method, url, err)
+			reasons = append(reasons, "{"+err.Error()+"}")
+			continue
+		}
+		reasons = append(reasons, "{"+rule.Method+" "+strconv.Itoa(rule.Port)+rule.Path+" "+resp.Body+"}")
+	}
+	return reasons
+}
+
+// ClearPodState drop every record of a pod
+func (sm *SmoothManager) ClearPodState(namespace string, podName string) error {
+	for _, kind := range model.Kinds {
+		value, err := sm.Store.Get(kind, namespace, podName)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		keyClear := storeKey(kind, namespace, podName)
+		if err := sm.Store.Del(kind, namespace, podName); err != nil {
+			return fmt.Errorf("DEL[%s]: %v", keyClear, err)
+		}
+		glog.Infof("SUCCESS: DEL[%s]", keyClear)
+	}
+	return nil
+}
+
+// GetSmooth Smooth namespace/name from the apiserver
+func (sm *SmoothManager) GetSmooth(namespace string, name string) (*v1alpha1.Smooth, error) {
+	obj, err := sm.ClientSmooth.Resource(smoothGVR).Namespace(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return toSmooth(obj)
+}
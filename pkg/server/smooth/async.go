@@ -0,0 +1,123 @@
+package smooth
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// notReadyDelay wait after traffic is isolated, avoid network reclaim of a terminating pod breaking requests
+const notReadyDelay = 5 * time.Second
+
+// PodSmoothConfig Smooth of pod, cached in KindLabel once its smLabel is applied
+func (sm *SmoothManager) PodSmoothConfig(pod corev1.Pod) (*v1alpha1.Smooth, bool, error) {
+	valueSmLabeled, _ := sm.Store.Get(model.KindLabel, pod.Namespace, pod.Name)
+	if valueSmLabeled != "" {
+		var smConfig *v1alpha1.Smooth
+		if err := json.Unmarshal([]byte(valueSmLabeled), &smConfig); err != nil {
+			return nil, true, err
+		}
+		return smConfig, true, nil
+	}
+	smConfig, err := sm.GetSmoothConfig(pod)
+	return smConfig, false, err
+}
+
+// AsyncEvaluation evaluation of the Smooth, default the one of admiteed
+func (sm *SmoothManager) AsyncEvaluation(smConfig *v1alpha1.Smooth) bool {
+	evaluation := sm.Evaluation
+	if smConfig != nil && smConfig.Spec.Evaluation != "" {
+		evaluation = smConfig.Spec.Evaluation
+	}
+	return strings.EqualFold(evaluation, v1alpha1.EvaluationAsync)
+}
+
+// CachedRuleResults answer admission from the results of the background prober, missing or stale results are refreshed
+func (sm *SmoothManager) CachedRuleResults(pod corev1.Pod, smConfig *v1alpha1.Smooth) (bool, []string, string) {
+	if len(smConfig.Spec.Rules) == 0 {
+		return true, nil, decisionRulesPassed
+	}
+
+	record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name)
+	if err != nil {
+		return false, []string{"{" + err.Error() + "}"}, decisionStoreError
+	}
+	interval := v1alpha1.DefaultInterval
+	if record != nil && record.Interval > 0 {
+		interval = record.Interval
+	}
+	if record == nil || len(record.RuleResults) == 0 ||
+		time.Since(record.RuleResults[0].ProbeTime.Time) > 2*time.Duration(interval)*time.Second {
+		sm.refreshAsync(pod)
+		return false, []string{"{rules pending}"}, decisionPending
+	}
+
+	var allowed = true
+	var decision = decisionRulesPassed
+	var reasons []string
+	for _, result := range record.RuleResults {
+		if !result.Passed {
+			allowed = false
+			decision = decisionRuleUnexpected
+		}
+		if result.Error != "" {
+			reasons = append(reasons, "{"+result.Error+"}")
+		} else {
+			reasons = append(reasons, "{"+result.Rule+" "+result.Response+"}")
+		}
+	}
+	return allowed, reasons, decision
+}
+
+// RefreshRuleResults probe the rules of a smoothing pod outside admission, results are kept in its PodRecord
+func (sm *SmoothManager) RefreshRuleResults(pod corev1.Pod) error {
+	key := "LOCK_PROBE_" + pod.Namespace + "_" + pod.Name
+	if locked, err := sm.Store.Lock(key); err != nil {
+		return err
+	} else if !locked {
+		// probing by another request or replica
+		return nil
+	}
+	defer sm.Store.UnLock(key)
+
+	smConfig, _, err := sm.PodSmoothConfig(pod)
+	if err != nil || smConfig == nil {
+		return err
+	}
+	allowed, reasons, decision := sm.EvaluateRules(pod, smConfig)
+	glog.Infof("MESSAGE: POD[%v],Probe[%v],Decision[%v],Reason[%v]", pod.Namespace+"/"+pod.Name, allowed, decision, strings.Join(reasons, ","))
+	return nil
+}
+
+// refreshAsync probe without blocking admission, the background context outlives the request
+func (sm *SmoothManager) refreshAsync(pod corev1.Pod) {
+	prober := *sm
+	prober.Ctx = context.Background()
+	prober.Deadline = 0
+	go prober.RefreshRuleResults(pod)
+}
+
+// notReadyWait time left of notReadyDelay, the delay starts with the first call
+func (sm *SmoothManager) notReadyWait(pod corev1.Pod) time.Duration {
+	value, _ := sm.Store.Get(model.KindNotReady, pod.Namespace, pod.Name)
+	if value == "" {
+		value = strconv.FormatInt(time.Now().Unix(), 10)
+		ok, err := sm.Store.SetNX(model.KindNotReady, pod.Namespace, pod.Name, value)
+		if err == nil && ok {
+			glog.Infof("SUCCESS: SET[%s:%s]", storeKey(model.KindNotReady, pod.Namespace, pod.Name), value)
+		}
+	}
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Until(time.Unix(since, 0).Add(notReadyDelay))
+}
@@ -0,0 +1,116 @@
+package smooth
+
+import (
+	"encoding/json"
+	"sort"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var clusterSmoothGVR = schema.GroupVersionResource{
+	Group:    v1alpha1.Group,
+	Version:  v1alpha1.Version,
+	Resource: v1alpha1.ResourceCluster,
+}
+
+// GetClusterSmoothConfig ClusterSmooth applied to pod as a Smooth of its namespace, nil when none matches
+func (sm *SmoothManager) GetClusterSmoothConfig(pod corev1.Pod) (*v1alpha1.Smooth, error) {
+	list, err := sm.ClientSmooth.Resource(clusterSmoothGVR).List(sm.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := list.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var csmList v1alpha1.ClusterSmoothList
+	if err := json.Unmarshal(data, &csmList); err != nil {
+		return nil, err
+	}
+	if len(csmList.Items) == 0 {
+		return nil, nil
+	}
+
+	ns, err := sm.ClientKubeSet.CoreV1().Namespaces().Get(sm.Ctx, pod.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []v1alpha1.ClusterSmooth
+	for _, csm := range csmList.Items {
+		if !selectorMatches(csm.Spec.NamespaceSelector, ns.Labels) || !selectorMatches(csm.Spec.Selector, pod.Labels) {
+			continue
+		}
+		matched = append(matched, csm)
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	// same order as selector Smooths: higher priority, more specific, then name
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		if specA, specB := clusterSpecificity(a), clusterSpecificity(b); specA != specB {
+			return specA > specB
+		}
+		return a.Name < b.Name
+	})
+	if len(matched) > 1 {
+		glog.Infof("MESSAGE: POD[%s/%s] ClusterSmooths Matched[%v] Applied[%s]", pod.Namespace, pod.Name, len(matched), matched[0].Name)
+	}
+
+	csm := matched[0]
+	spec := csm.Spec.SmoothSpec
+	spec.TargetRef.Kind, spec.TargetRef.Name = "", ""
+	return &v1alpha1.Smooth{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.Group + "/" + v1alpha1.Version,
+			Kind:       v1alpha1.KindCluster,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      csm.Name,
+			Namespace: pod.Namespace,
+			UID:       csm.UID,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// selectorMatches a nil selector matches everything
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		glog.Errorf("FAILURE: Selector[%v]", err)
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+func clusterSpecificity(csm v1alpha1.ClusterSmooth) int {
+	var count int
+	for _, selector := range []*metav1.LabelSelector{csm.Spec.NamespaceSelector, csm.Spec.Selector} {
+		if selector != nil {
+			count += len(selector.MatchLabels) + len(selector.MatchExpressions)
+		}
+	}
+	return count
+}
+
+// smoothRecordName name of the applied Smooth in pod records, ClusterSmooths are prefixed by their kind
+func smoothRecordName(smConfig *v1alpha1.Smooth) string {
+	if smConfig.Kind == v1alpha1.KindCluster {
+		return v1alpha1.KindCluster + "/" + smConfig.Name
+	}
+	return smConfig.Name
+}
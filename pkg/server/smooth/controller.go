@@ -0,0 +1,380 @@
+package smooth
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/metrics"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// resyncRecords period to re-enqueue every record, catches pods deleted while admitee was down
+	resyncRecords = time.Hour
+	podWorkers    = 2
+	smoothWorkers = 1
+)
+
+// Controller re-probes smoothing pods on their interval and cleans records of deleted pods,
+// driven by pod and Smooth informers instead of polling the store. Only the elected leader runs it.
+type Controller struct {
+	sm *SmoothManager
+
+	podLister      corelisters.PodLister
+	podSynced      cache.InformerSynced
+	smoothInformer cache.SharedIndexInformer
+
+	podQueue    workqueue.RateLimitingInterface
+	smoothQueue workqueue.RateLimitingInterface
+	// pods to re-probe before their interval, e.g. readiness changed
+	probeNow sync.Map
+}
+
+func NewController(sm *SmoothManager, kubeInformers informers.SharedInformerFactory, smoothInformers dynamicinformer.DynamicSharedInformerFactory) *Controller {
+	podInformer := kubeInformers.Core().V1().Pods()
+	c := &Controller{
+		sm:             sm,
+		podLister:      podInformer.Lister(),
+		podSynced:      podInformer.Informer().HasSynced,
+		smoothInformer: smoothInformers.ForResource(smoothGVR).Informer(),
+		podQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "smooth-pods"),
+		smoothQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "smooths"),
+	}
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok && (gatePending(pod) || pod.Annotations[v1alpha1.AnnotationSmoothing] != "") {
+				c.enqueuePod(pod)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			newPod, ok := newObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			if gatePending(newPod) || newPod.Annotations[v1alpha1.AnnotationAbort] == "true" ||
+				oldPod.Annotations[v1alpha1.AnnotationSmoothing] != newPod.Annotations[v1alpha1.AnnotationSmoothing] {
+				c.enqueuePod(newPod)
+			}
+			if podReady(oldPod) != podReady(newPod) || (oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil) {
+				if key, err := cache.MetaNamespaceKeyFunc(newPod); err == nil {
+					c.probeNow.Store(key, true)
+				}
+				c.enqueuePod(newPod)
+			}
+		},
+		DeleteFunc: c.enqueuePod,
+	})
+
+	c.smoothInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueSmooth,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// status written by syncSmooth comes back as an update, only spec and annotations need a sync
+			oldMeta, errOld := meta.Accessor(oldObj)
+			newMeta, errNew := meta.Accessor(newObj)
+			if errOld == nil && errNew == nil && oldMeta.GetGeneration() == newMeta.GetGeneration() &&
+				reflect.DeepEqual(oldMeta.GetAnnotations(), newMeta.GetAnnotations()) {
+				return
+			}
+			c.enqueueSmooth(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			deleted := obj
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				deleted = tombstone.Obj
+			}
+			if smooth, err := toSmooth(deleted); err == nil {
+				kindLabel, targetLabel := smoothTargetLabels(*smooth)
+				metrics.SmoothingPods.DeleteLabelValues(smooth.Namespace, kindLabel, targetLabel)
+			}
+			c.enqueueSmooth(obj)
+		},
+	})
+
+	return c
+}
+
+func (c *Controller) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer c.podQueue.ShutDown()
+	defer c.smoothQueue.ShutDown()
+
+	glog.Infof("Starting smooth controller")
+	if !cache.WaitForCacheSync(ctx.Done(), c.podSynced, c.smoothInformer.HasSynced) {
+		glog.Errorf("FAILURE: Wait For Cache Sync")
+		return
+	}
+
+	go wait.Until(c.enqueueRecords, resyncRecords, ctx.Done())
+	for i := 0; i < podWorkers; i++ {
+		go wait.Until(func() {
+			for c.processNext(c.podQueue, c.syncPod) {
+			}
+		}, time.Second, ctx.Done())
+	}
+	for i := 0; i < smoothWorkers; i++ {
+		go wait.Until(func() {
+			for c.processNext(c.smoothQueue, c.syncSmooth) {
+			}
+		}, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+	glog.Infof("Stopping smooth controller")
+}
+
+func (c *Controller) processNext(queue workqueue.RateLimitingInterface, sync func(key string) error) bool {
+	obj, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(obj)
+
+	key := obj.(string)
+	if err := sync(key); err != nil {
+		glog.Errorf("FAILURE: Sync[%s]: %v", key, err)
+		queue.AddRateLimited(key)
+		return true
+	}
+	queue.Forget(key)
+	return true
+}
+
+func (c *Controller) enqueuePod(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.podQueue.Add(key)
+}
+
+func (c *Controller) enqueueSmooth(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.smoothQueue.Add(key)
+}
+
+// enqueueRecords every pod known by the store, pods deleted while nobody watched are cleaned
+func (c *Controller) enqueueRecords() {
+	for _, kind := range model.Kinds {
+		entries, err := c.sm.Store.List(kind, "")
+		if err != nil {
+			glog.Errorf("FAILURE: %s LIST: %v", kind, err)
+			continue
+		}
+		for _, entry := range entries {
+			c.podQueue.Add(entry.Namespace + "/" + entry.Name)
+		}
+	}
+}
+
+// enqueueSmoothOfTarget status of the Smooth applied, or targeting kind/name, depends on its smoothing pods
+func (c *Controller) enqueueSmoothOfTarget(namespace string, kind string, name string, smoothName string) {
+	objs, err := c.smoothInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return
+	}
+	for _, obj := range objs {
+		smooth, err := toSmooth(obj)
+		if err != nil {
+			continue
+		}
+		if smooth.Name == smoothName || (smooth.Spec.TargetRef.Kind == kind && smooth.Spec.TargetRef.Name == name) {
+			c.smoothQueue.Add(namespace + "/" + smooth.Name)
+		}
+	}
+}
+
+func (c *Controller) syncPod(key string) error {
+	namespace, podName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	pod, err := c.podLister.Pods(namespace).Get(podName)
+	if errors.IsNotFound(err) {
+		return c.cleanPod(namespace, podName)
+	} else if err != nil {
+		return err
+	}
+
+	if pod.Annotations[v1alpha1.AnnotationAbort] == "true" {
+		return c.abortPod(pod)
+	}
+
+	record, err := model.GetPodRecord(c.sm.Store, namespace, podName)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		// pods not in smoothing serve, open the injected readiness gate
+		if pod.DeletionTimestamp == nil && hasReadinessGate(pod) {
+			return c.sm.SetReadinessGate(*pod, corev1.ConditionTrue, GateReasonServing, "")
+		}
+		return nil
+	}
+	defer c.enqueueSmoothOfTarget(namespace, record.TargetKind, record.TargetName, record.SmoothName)
+
+	keyPOD := storeKey(model.KindPod, namespace, podName)
+	interval := time.Duration(record.Interval) * time.Second
+	_, now := c.probeNow.LoadAndDelete(key)
+	if next := record.LastProbe.Add(interval); !now && next.After(time.Now()) {
+		c.podQueue.AddAfter(key, time.Until(next))
+		return nil
+	}
+
+	var errDEL error
+	if pod.DeletionTimestamp == nil && c.sm.TimeoutExceeded(*pod, record) && record.TimeoutPolicy == v1alpha1.TimeoutPolicyAllow {
+		// Allow waits for the next delete request, Deny keeps probing until the rules pass or it is aborted
+		return nil
+	}
+	if pod.DeletionTimestamp == nil {
+		// async Smooths answer admission from cached results, probe them right before the delete
+		if smConfig, _, err := c.sm.PodSmoothConfig(*pod); err == nil && smConfig != nil && c.sm.AsyncEvaluation(smConfig) {
+			if err := c.sm.RefreshRuleResults(*pod); err != nil {
+				glog.Errorf("FAILURE: Probe[%s]: %v", keyPOD, err)
+			}
+		}
+		//POD存在，则删除POD，删除请求经过准入重新执行规则
+		errDEL = c.sm.ClientKubeSet.CoreV1().Pods(namespace).Delete(c.sm.Ctx, podName, metav1.DeleteOptions{})
+		if errDEL != nil {
+			//删除失败，更新记录，重新读取以保留准入过程写入的规则结果
+			if latest, err := model.GetPodRecord(c.sm.Store, namespace, podName); err == nil && latest != nil {
+				record = latest
+			}
+			record.LastProbe = time.Now()
+			record.Count++
+			if err := model.SetPodRecord(c.sm.Store, namespace, podName, record); err != nil {
+				return fmt.Errorf("SET[%s]: %v", keyPOD, err)
+			}
+			glog.Infof("SUCCESS: SET[%v] Count[%v]", keyPOD, record.Count)
+		}
+	}
+
+	if errDEL == nil {
+		valueDelete, _ := c.sm.Store.Get(model.KindDelete, namespace, podName)
+		if valueDelete == "" {
+			//删除记录
+			if err := c.sm.Store.Del(model.KindPod, namespace, podName); err != nil {
+				return fmt.Errorf("DEL[%s]: %v", keyPOD, err)
+			}
+			glog.Infof("SUCCESS: DEL[%s]", keyPOD)
+		}
+		return nil
+	}
+
+	c.podQueue.AddAfter(key, interval)
+	return nil
+}
+
+// abortPod abort the smoothing of an annotated pod, the annotation is removed once done
+func (c *Controller) abortPod(pod *corev1.Pod) error {
+	record, _ := model.GetPodRecord(c.sm.Store, pod.Namespace, pod.Name)
+	if record != nil {
+		defer c.enqueueSmoothOfTarget(pod.Namespace, record.TargetKind, record.TargetName, record.SmoothName)
+	}
+	if err := c.sm.AbortSmoothing(*pod, "annotation "+v1alpha1.AnnotationAbort); err == ErrNotSmoothing {
+		glog.Infof("MESSAGE: Abort POD[%s/%s] not in smoothing", pod.Namespace, pod.Name)
+	} else if err != nil {
+		return err
+	}
+	patch := []byte(`{"metadata":{"annotations":{"` + v1alpha1.AnnotationAbort + `":null}}}`)
+	_, err := c.sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Patch(c.sm.Ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// cleanPod drop every record of a deleted pod
+func (c *Controller) cleanPod(namespace string, podName string) error {
+	record, _ := model.GetPodRecord(c.sm.Store, namespace, podName)
+	if record != nil {
+		defer c.enqueueSmoothOfTarget(namespace, record.TargetKind, record.TargetName, record.SmoothName)
+	}
+
+	return c.sm.ClearPodState(namespace, podName)
+}
+
+func (c *Controller) syncSmooth(key string) error {
+	obj, exists, err := c.smoothInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	smooth, err := toSmooth(obj)
+	if err != nil {
+		return err
+	}
+	if smooth.Annotations[v1alpha1.AnnotationAbort] == "true" {
+		aborted, err := c.sm.AbortSmooth(smooth, "annotation "+v1alpha1.AnnotationAbort)
+		if err != nil {
+			return fmt.Errorf("Abort Smooth[%s]: %v", key, err)
+		}
+		glog.Infof("SUCCESS: Abort Smooth[%s] PODS[%s]", key, strings.Join(aborted, ","))
+		patch := []byte(`{"metadata":{"annotations":{"` + v1alpha1.AnnotationAbort + `":null}}}`)
+		if _, err := c.sm.ClientSmooth.Resource(smoothGVR).Namespace(smooth.Namespace).Patch(c.sm.Ctx, smooth.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("Abort Smooth[%s]: %v", key, err)
+		}
+		// the patch brings the Smooth back with a fresh resourceVersion for its status
+		return nil
+	}
+	if err := c.sm.SyncSmoothStatus(smooth, c.podLister); err != nil {
+		return fmt.Errorf("Update Smooth Status[%s]: %v", key, err)
+	}
+	return nil
+}
+
+func toSmooth(obj interface{}) (*v1alpha1.Smooth, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T", obj)
+	}
+	var smooth v1alpha1.Smooth
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &smooth); err != nil {
+		return nil, err
+	}
+	return &smooth, nil
+}
+
+// gatePending pod has the injected readiness gate without its condition
+func gatePending(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && hasReadinessGate(pod) && readinessGateStatus(pod) == ""
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, i := range pod.Status.Conditions {
+		if i.Type == corev1.PodReady {
+			return i.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
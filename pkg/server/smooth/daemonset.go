@@ -2,10 +2,11 @@ package smooth
 
 import (
 	"strconv"
-	"strings"
 
 	"github.com/golang/glog"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func (sm *SmoothManager) VerifyDeletePodDaemonSet(namespace string, dsName string, countUpdate int) (bool, string) {
@@ -15,17 +16,35 @@ func (sm *SmoothManager) VerifyDeletePodDaemonSet(namespace string, dsName strin
 		return false, "DaemonSet GET[" + err.Error() + "]"
 	}
 
-	maxuvfloat64, err := strconv.ParseFloat(strings.Replace(dsdetail.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable.StrVal, "%", "", -1), 64)
+	// maxUnavailable defaults to 1, OnDelete DaemonSets are replaced by hand one node at a time
+	maxUnavailable := intstr.FromInt(1)
+	maxSurge := intstr.FromInt(0)
+	if ru := dsdetail.Spec.UpdateStrategy.RollingUpdate; dsdetail.Spec.UpdateStrategy.Type != appsv1.OnDeleteDaemonSetStrategyType && ru != nil {
+		if ru.MaxUnavailable != nil {
+			maxUnavailable = *ru.MaxUnavailable
+		}
+		if ru.MaxSurge != nil {
+			maxSurge = *ru.MaxSurge
+		}
+	}
+	desired := int(dsdetail.Status.DesiredNumberScheduled)
+	countMaxuav, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, desired, true)
 	if err != nil {
-		glog.Errorf("FAILURE: Can't encode maxuvfloat64[%v]", err)
+		glog.Errorf("FAILURE: DaemonSet MaxUnavailable[%v]", err)
 		return false, "DaemonSet MaxUnavailable[" + err.Error() + "]"
 	}
-	maxuvpercent := maxuvfloat64 * 0.01
-	countMaxuav := int(float64(dsdetail.Status.DesiredNumberScheduled) * maxuvpercent)
+	// maxUnavailable 0 with maxSurge, the new pod starts on the node before the old one is deleted
 	if countMaxuav == 0 {
+		countMaxuav, err = intstr.GetScaledValueFromIntOrPercent(&maxSurge, desired, true)
+		if err != nil {
+			glog.Errorf("FAILURE: DaemonSet MaxSurge[%v]", err)
+			return false, "DaemonSet MaxSurge[" + err.Error() + "]"
+		}
+	}
+	if countMaxuav < 1 {
 		countMaxuav = 1
 	}
-	glog.Infof("MESSAGE: DaemonSet[%s] SmoothCount: %v, MaxUnavailableCount: %v, DesiredNumber：%v, MaxUnavailable:%v", dsName, countUpdate, countMaxuav, dsdetail.Status.DesiredNumberScheduled, dsdetail.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable.StrVal)
+	glog.Infof("MESSAGE: DaemonSet[%s] SmoothCount[%v] MaxUnavailableCount[%v] DesiredNumber[%v] MaxUnavailable[%v] MaxSurge[%v] UpdateStrategy[%v]", dsName, countUpdate, countMaxuav, desired, maxUnavailable.String(), maxSurge.String(), dsdetail.Spec.UpdateStrategy.Type)
 
 	//删除副本数大于等于最大不可用副本数时，拒绝删除
 	if countUpdate >= countMaxuav {
@@ -0,0 +1,137 @@
+package smooth
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func intOrString(value intstr.IntOrString) *intstr.IntOrString {
+	return &value
+}
+
+func TestVerifyDeletePodDaemonSet(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    appsv1.DaemonSetUpdateStrategy
+		desired     int32
+		countUpdate int
+		allowed     bool
+		reason      string
+	}{
+		{
+			name:        "nil rollingUpdate defaults to 1",
+			strategy:    appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType},
+			desired:     10,
+			countUpdate: 0,
+			allowed:     true,
+			reason:      "DaemonSet maxUnavailable[0/1]",
+		},
+		{
+			name:        "nil rollingUpdate exceeded",
+			strategy:    appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType},
+			desired:     10,
+			countUpdate: 1,
+			allowed:     false,
+			reason:      "DaemonSet exceed maxUnavailable[1/1]",
+		},
+		{
+			name: "OnDelete ignores rollingUpdate",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.OnDeleteDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: intOrString(intstr.FromInt(5))},
+			},
+			desired:     10,
+			countUpdate: 1,
+			allowed:     false,
+			reason:      "DaemonSet exceed maxUnavailable[1/1]",
+		},
+		{
+			name: "integer maxUnavailable",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: intOrString(intstr.FromInt(3))},
+			},
+			desired:     10,
+			countUpdate: 2,
+			allowed:     true,
+			reason:      "DaemonSet maxUnavailable[2/3]",
+		},
+		{
+			name: "percent maxUnavailable rounds up",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: intOrString(intstr.FromString("25%"))},
+			},
+			desired:     10,
+			countUpdate: 3,
+			allowed:     false,
+			reason:      "DaemonSet exceed maxUnavailable[3/3]",
+		},
+		{
+			name: "percent maxUnavailable at least 1",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: intOrString(intstr.FromString("0%"))},
+			},
+			desired:     10,
+			countUpdate: 0,
+			allowed:     true,
+			reason:      "DaemonSet maxUnavailable[0/1]",
+		},
+		{
+			name: "maxSurge when maxUnavailable is 0",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: intOrString(intstr.FromInt(0)),
+					MaxSurge:       intOrString(intstr.FromString("20%")),
+				},
+			},
+			desired:     10,
+			countUpdate: 1,
+			allowed:     true,
+			reason:      "DaemonSet maxUnavailable[1/2]",
+		},
+		{
+			name: "invalid maxUnavailable",
+			strategy: appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: intOrString(intstr.FromString("ten"))},
+			},
+			desired:     10,
+			countUpdate: 0,
+			allowed:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent"},
+				Spec:       appsv1.DaemonSetSpec{UpdateStrategy: tt.strategy},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: tt.desired},
+			}
+			sm := &SmoothManager{ClientKubeSet: fake.NewSimpleClientset(ds), Ctx: context.Background()}
+
+			allowed, reason := sm.VerifyDeletePodDaemonSet("default", "agent", tt.countUpdate)
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v, reason %s", allowed, tt.allowed, reason)
+			}
+			if tt.reason != "" && reason != tt.reason {
+				t.Errorf("reason = %s, want %s", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyDeletePodDaemonSetNotFound(t *testing.T) {
+	sm := &SmoothManager{ClientKubeSet: fake.NewSimpleClientset(), Ctx: context.Background()}
+	if allowed, reason := sm.VerifyDeletePodDaemonSet("default", "missing", 0); allowed {
+		t.Errorf("allowed a missing DaemonSet, reason %s", reason)
+	}
+}
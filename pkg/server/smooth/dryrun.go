@@ -0,0 +1,70 @@
+package smooth
+
+import (
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/model"
+	"admitee/pkg/utils"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DryRunPod decision of a dry-run delete or eviction without writes: no records, labels,
+// readiness gate or events. Rules are not probed since they may isolate the pod, cached results are used
+func (sm *SmoothManager) DryRunPod(pod corev1.Pod) (bool, string, string) {
+	if ok, reason, decision := podPhaseDecision(pod); ok {
+		return ok, reason, decision
+	}
+
+	valueDelete, err := sm.Store.Get(model.KindDelete, pod.Namespace, pod.Name)
+	if err != nil {
+		return sm.failureResponse(pod, componentStore, err)
+	}
+	if valueDelete != "" {
+		return true, "{dry run, delete allowed before}", decisionRulesPassed
+	}
+
+	smConfig, _, err := sm.PodSmoothConfig(pod)
+	if Unavailable(err) {
+		return sm.failureResponse(pod, componentAPIServer, err)
+	} else if err != nil {
+		return false, err.Error(), decisionConfigError
+	}
+	if smConfig == nil {
+		return true, "{dry run, Smooth Config NOT SET}", decisionNoConfig
+	}
+
+	record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name)
+	if err != nil {
+		return sm.failureResponse(pod, componentStore, err)
+	}
+	if record == nil {
+		_, nameOwnerReference, _ := utils.GetOwnerReference(pod)
+		countUpdate, err := sm.CountSmoothingPodsByOwnerReferenceName(pod.Namespace, nameOwnerReference)
+		if err != nil {
+			return sm.failureResponse(pod, componentStore, err)
+		}
+		if ok, reason := sm.VerifyDeleteBudget(pod, countUpdate); !ok {
+			return false, reason, decisionBudgetExceeded
+		}
+		return false, "{dry run, smoothing not started}", decisionPending
+	}
+
+	if record.TimedOut && record.TimeoutPolicy != v1alpha1.TimeoutPolicyDeny {
+		return true, "{dry run, smoothing timeout policy " + record.TimeoutPolicy + "}", decisionTimeout
+	}
+
+	// pods in smoothing answer from the results of their last probe
+	allowed := len(record.RuleResults) > 0 || len(smConfig.Spec.Rules) == 0
+	for _, result := range record.RuleResults {
+		allowed = allowed && result.Passed
+	}
+	if allowed && podReady(&pod) {
+		return false, "{dry run, pod status Ready}", decisionPodReady
+	}
+	if !allowed {
+		return false, "{dry run, rules not passed}", decisionRuleUnexpected
+	}
+	glog.Infof("MESSAGE: POD[%s/%s] dry run allowed", pod.Namespace, pod.Name)
+	return true, "{dry run, rules passed}", decisionRulesPassed
+}
@@ -0,0 +1,53 @@
+package smooth
+
+import (
+	"strings"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// event reasons recorded on the pod and its Smooth
+const (
+	EventSmoothingStarted = "SmoothingStarted"
+	EventRuleFailed       = "RuleFailed"
+	EventTrafficIsolated  = "TrafficIsolated"
+	EventDeleteAllowed    = "DeleteAllowed"
+	EventSmoothTimeout    = "SmoothTimeout"
+	EventSmoothAborted    = "SmoothingAborted"
+)
+
+// NewEventRecorder shared by admission and controller, events are reported by component admiteed
+func NewEventRecorder(clientKubeSet kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(glog.Infof)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientKubeSet.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "admiteed"})
+}
+
+// Eventf record an event on pod, and on the Smooth named smoothName when set,
+// ClusterSmooth/name for a ClusterSmooth
+func (sm *SmoothManager) Eventf(pod corev1.Pod, smoothName string, eventtype string, reason string, messageFmt string, args ...interface{}) {
+	if sm.Recorder == nil {
+		return
+	}
+	sm.Recorder.Eventf(&pod, eventtype, reason, messageFmt, args...)
+	if smoothName != "" {
+		ref := &corev1.ObjectReference{
+			APIVersion: v1alpha1.Group + "/" + v1alpha1.Version,
+			Kind:       "Smooth",
+			Namespace:  pod.Namespace,
+			Name:       smoothName,
+		}
+		if name := strings.TrimPrefix(smoothName, v1alpha1.KindCluster+"/"); name != smoothName {
+			ref.Kind, ref.Namespace, ref.Name = v1alpha1.KindCluster, "", name
+		}
+		sm.Recorder.Eventf(ref, eventtype, reason, "POD["+pod.Name+"] "+messageFmt, args...)
+	}
+}
@@ -0,0 +1,62 @@
+package smooth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetEvictionPod resolves the pod targeted by a pods/eviction request
+func (sm *SmoothManager) GetEvictionPod(req *admissionv1.AdmissionRequest) (corev1.Pod, error) {
+	var pod corev1.Pod
+
+	// policy/v1 and policy/v1beta1 Eviction share the same layout
+	var eviction policyv1.Eviction
+	if len(req.Object.Raw) != 0 {
+		if err := json.Unmarshal(req.Object.Raw, &eviction); err != nil {
+			return pod, fmt.Errorf("Eviction Unmarshal[%v]", err)
+		}
+	}
+
+	namespace := eviction.Namespace
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+	name := eviction.Name
+	if name == "" {
+		name = req.Name
+	}
+	if name == "" {
+		return pod, fmt.Errorf("Eviction POD NOT SET")
+	}
+
+	podGet, err := sm.ClientKubeSet.CoreV1().Pods(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	if err != nil {
+		glog.Errorf("FAILURE: Get POD[%s/%s]: %v", namespace, name, err)
+		return pod, err
+	}
+	return *podGet, nil
+}
+
+// returnEvictionResponse denies with 429 so kubectl drain and cluster-autoscaler retry the eviction
+func returnEvictionResponse(allowed bool, reason string) *admissionv1.AdmissionResponse {
+	if allowed {
+		return returnAdmissionResponse(allowed, reason)
+	}
+
+	return &admissionv1.AdmissionResponse{
+		Allowed: allowed,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusTooManyRequests,
+			Reason:  metav1.StatusReasonTooManyRequests,
+			Message: reason,
+		},
+	}
+}
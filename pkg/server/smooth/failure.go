@@ -0,0 +1,68 @@
+package smooth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// components whose outage the failure policy answers for
+const (
+	componentStore     = "store"
+	componentAPIServer = "apiserver"
+)
+
+// Unavailable errors of an unreachable or overloaded apiserver, other errors keep their own handling.
+// Deadlines of the admission request itself are not an outage, its rules must not be skipped
+func Unavailable(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// failureResponse answer a delete right away when component can not be reached,
+// Ignore allows it and Fail denies it
+func (sm *SmoothManager) failureResponse(pod corev1.Pod, component string, err error) (bool, string, string) {
+	policy := sm.podFailurePolicy(pod, component)
+	decision := decisionStoreUnavailable
+	if component == componentAPIServer {
+		decision = decisionAPIUnavailable
+	}
+	glog.Errorf("FAILURE: POD[%s/%s] %s unavailable, FailurePolicy[%s]: %v", pod.Namespace, pod.Name, component, policy, err)
+	return policy == v1alpha1.FailurePolicyIgnore, "{" + component + " unavailable, failurePolicy " + policy + ": " + err.Error() + "}", decision
+}
+
+// podFailurePolicy failurePolicy of the Smooth of pod read from the component still reachable, else --failure-policy
+func (sm *SmoothManager) podFailurePolicy(pod corev1.Pod, component string) string {
+	var smConfig *v1alpha1.Smooth
+	switch component {
+	case componentStore:
+		smConfig, _ = sm.GetSmoothConfig(pod)
+	case componentAPIServer:
+		// Smooth cached in the store once smLabel is applied
+		if value, err := sm.Store.Get(model.KindLabel, pod.Namespace, pod.Name); err == nil && value != "" {
+			json.Unmarshal([]byte(value), &smConfig)
+		}
+	}
+	if smConfig != nil && smConfig.Spec.FailurePolicy != "" {
+		return smConfig.Spec.FailurePolicy
+	}
+	if sm.FailurePolicy != "" {
+		return sm.FailurePolicy
+	}
+	return v1alpha1.FailurePolicyFail
+}
@@ -0,0 +1,227 @@
+package smooth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/utils"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// jsonPathComparison "<path> <operator> <value>", operators need spaces around them so filters keep working
+var jsonPathComparison = regexp.MustCompile(`^(\S+)\s+(==|!=|<=|>=|<|>)\s+(.+)$`)
+
+// HasStatusMatcher rules without one treat any status but 200 as a request error
+func HasStatusMatcher(rule v1alpha1.Rule) bool {
+	for _, m := range rule.Matchers {
+		if len(m.Status) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchResponse returns false with the first failed assertion, error when a matcher is invalid
+func MatchResponse(rule v1alpha1.Rule, resp *utils.RestApiResponse) (bool, string, error) {
+	expect := strings.TrimSpace(rule.Expect)
+	if (len(rule.Matchers) == 0 || expect != "") && resp.Body != expect {
+		return false, "body " + expect, nil
+	}
+
+	for _, m := range rule.Matchers {
+		passed, err := matchOne(m, resp)
+		if err != nil {
+			return false, DescribeMatcher(m), err
+		}
+		if passed == m.Negate {
+			return false, DescribeMatcher(m), nil
+		}
+	}
+	return true, "", nil
+}
+
+// DescribeExpect readable assertions of a rule for reasons and status
+func DescribeExpect(rule v1alpha1.Rule) string {
+	var expects []string
+	if expect := strings.TrimSpace(rule.Expect); len(rule.Matchers) == 0 || expect != "" {
+		expects = append(expects, expect)
+	}
+	for _, m := range rule.Matchers {
+		expects = append(expects, DescribeMatcher(m))
+	}
+	return strings.Join(expects, ",")
+}
+
+func DescribeMatcher(m v1alpha1.Matcher) string {
+	var desc string
+	switch {
+	case len(m.Status) > 0:
+		desc = fmt.Sprintf("status in %v", m.Status)
+	case m.Regex != "":
+		desc = "regex " + m.Regex
+	case m.JSONPath != "":
+		desc = "jsonPath " + m.JSONPath
+	case m.Header != "" && m.Value != "":
+		desc = "header " + m.Header + " =~ " + m.Value
+	case m.Header != "":
+		desc = "header " + m.Header
+	}
+	if m.Negate {
+		desc = "not " + desc
+	}
+	return desc
+}
+
+// ValidateMatcher same checks MatchResponse applies before matching
+func ValidateMatcher(m v1alpha1.Matcher) error {
+	var set int
+	for _, ok := range []bool{len(m.Status) > 0, m.Regex != "", m.JSONPath != "", m.Header != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("Matcher needs exactly one of status, regex, jsonPath, header[%v]", m)
+	}
+	if m.Value != "" && m.Header == "" {
+		return fmt.Errorf("Matcher value only applies to header[%v]", m)
+	}
+
+	if m.Regex != "" {
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("Matcher regex[%s]: %v", m.Regex, err)
+		}
+	}
+	if m.Value != "" {
+		if _, err := regexp.Compile(m.Value); err != nil {
+			return fmt.Errorf("Matcher value[%s]: %v", m.Value, err)
+		}
+	}
+	if m.JSONPath != "" {
+		path, _, _ := parseJSONPath(m.JSONPath)
+		if err := jsonpath.New("matcher").Parse(path); err != nil {
+			return fmt.Errorf("Matcher jsonPath[%s]: %v", m.JSONPath, err)
+		}
+	}
+	return nil
+}
+
+func matchOne(m v1alpha1.Matcher, resp *utils.RestApiResponse) (bool, error) {
+	if err := ValidateMatcher(m); err != nil {
+		return false, err
+	}
+
+	switch {
+	case len(m.Status) > 0:
+		for _, code := range m.Status {
+			if resp.StatusCode == code {
+				return true, nil
+			}
+		}
+		return false, nil
+	case m.Regex != "":
+		return regexp.MustCompile(m.Regex).MatchString(resp.Body), nil
+	case m.Header != "":
+		values, ok := resp.Header[http.CanonicalHeaderKey(m.Header)]
+		if !ok {
+			return false, nil
+		}
+		if m.Value == "" {
+			return true, nil
+		}
+		re := regexp.MustCompile(m.Value)
+		for _, value := range values {
+			if re.MatchString(value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return matchJSONPath(m.JSONPath, resp.Body)
+}
+
+// parseJSONPath split "$.connections <= 0" into a client-go template, operator and value
+func parseJSONPath(expr string) (string, string, string) {
+	path, op, value := strings.TrimSpace(expr), "", ""
+	if match := jsonPathComparison.FindStringSubmatch(path); match != nil {
+		path, op, value = match[1], match[2], strings.Trim(strings.TrimSpace(match[3]), `"'`)
+	}
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	return path, op, value
+}
+
+// matchJSONPath body that is not JSON or a missing path fails the assertion
+func matchJSONPath(expr string, body string) (bool, error) {
+	path, op, value := parseJSONPath(expr)
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		return false, nil
+	}
+	j := jsonpath.New("matcher")
+	if err := j.Parse(path); err != nil {
+		return false, err
+	}
+	results, err := j.FindResults(data)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return false, nil
+	}
+	if op == "" {
+		return true, nil
+	}
+
+	// every value found must satisfy the comparison, e.g. connections of all backends
+	for _, result := range results[0] {
+		if !compare(fmt.Sprint(result.Interface()), op, value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compare numerically when both sides are numbers, as strings otherwise
+func compare(actual string, op string, expect string) bool {
+	a, errA := strconv.ParseFloat(actual, 64)
+	e, errE := strconv.ParseFloat(expect, 64)
+	if errA == nil && errE == nil {
+		switch op {
+		case "==":
+			return a == e
+		case "!=":
+			return a != e
+		case "<":
+			return a < e
+		case "<=":
+			return a <= e
+		case ">":
+			return a > e
+		case ">=":
+			return a >= e
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return actual == expect
+	case "!=":
+		return actual != expect
+	case "<":
+		return actual < expect
+	case "<=":
+		return actual <= expect
+	case ">":
+		return actual > expect
+	case ">=":
+		return actual >= expect
+	}
+	return false
+}
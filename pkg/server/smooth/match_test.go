@@ -0,0 +1,183 @@
+package smooth
+
+import (
+	"net/http"
+	"testing"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/utils"
+)
+
+func TestMatchResponse(t *testing.T) {
+	resp := &utils.RestApiResponse{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}, "X-Drain": []string{"done"}},
+		Body:       `{"status":"idle","connections":0,"backends":[{"connections":0},{"connections":2}]}`,
+	}
+
+	tests := []struct {
+		name    string
+		rule    v1alpha1.Rule
+		passed  bool
+		failed  string
+		wantErr bool
+	}{
+		{
+			name:   "expect only compares body",
+			rule:   v1alpha1.Rule{Expect: "ok"},
+			passed: false,
+			failed: "body ok",
+		},
+		{
+			name:   "status in list",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Status: []int{200, 204}}}},
+			passed: true,
+		},
+		{
+			name:   "status not in list",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Status: []int{503}}}},
+			passed: false,
+			failed: "status in [503]",
+		},
+		{
+			name:   "regex on body",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Regex: `"status":"idle"`}}},
+			passed: true,
+		},
+		{
+			name:   "negated regex",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Regex: "idle", Negate: true}}},
+			passed: false,
+			failed: "not regex idle",
+		},
+		{
+			name:   "jsonPath exists",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: "$.status"}}},
+			passed: true,
+		},
+		{
+			name:   "jsonPath missing",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: "$.draining"}}},
+			passed: false,
+			failed: "jsonPath $.draining",
+		},
+		{
+			name:   "jsonPath numeric comparison",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: "$.connections <= 0"}}},
+			passed: true,
+		},
+		{
+			name:   "jsonPath string comparison",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: `$.status == "idle"`}}},
+			passed: true,
+		},
+		{
+			name:   "jsonPath every value must compare",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: "$.backends[*].connections == 0"}}},
+			passed: false,
+			failed: "jsonPath $.backends[*].connections == 0",
+		},
+		{
+			name:   "header exists case insensitive",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Header: "x-drain"}}},
+			passed: true,
+		},
+		{
+			name:   "header value regex",
+			rule:   v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Header: "Content-Type", Value: "^text/"}}},
+			passed: false,
+			failed: "header Content-Type =~ ^text/",
+		},
+		{
+			name: "matchers and expect together",
+			rule: v1alpha1.Rule{
+				Expect:   "idle",
+				Matchers: []v1alpha1.Matcher{{Status: []int{200}}},
+			},
+			passed: false,
+			failed: "body idle",
+		},
+		{
+			name: "first failed matcher reported",
+			rule: v1alpha1.Rule{Matchers: []v1alpha1.Matcher{
+				{Status: []int{200}},
+				{Header: "X-Missing"},
+				{Regex: "busy"},
+			}},
+			passed: false,
+			failed: "header X-Missing",
+		},
+		{
+			name:    "matcher with two assertions",
+			rule:    v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Status: []int{200}, Regex: "idle"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			rule:    v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Regex: "("}}},
+			wantErr: true,
+		},
+		{
+			name:    "value without header",
+			rule:    v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{Regex: "idle", Value: "x"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed, failed, err := MatchResponse(tt.rule, resp)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("MatchResponse = %v, %s, want error", passed, failed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchResponse: %v", err)
+			}
+			if passed != tt.passed {
+				t.Errorf("passed = %v, want %v, failed %s", passed, tt.passed, failed)
+			}
+			if failed != tt.failed {
+				t.Errorf("failed = %s, want %s", failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestMatchResponseNotJSON(t *testing.T) {
+	resp := &utils.RestApiResponse{StatusCode: 200, Body: "ok"}
+	rule := v1alpha1.Rule{Matchers: []v1alpha1.Matcher{{JSONPath: "$.status"}}}
+	if passed, _, err := MatchResponse(rule, resp); passed || err != nil {
+		t.Errorf("MatchResponse on a plain body = %v, %v, want false", passed, err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		actual string
+		op     string
+		expect string
+		want   bool
+	}{
+		{"0", "==", "0", true},
+		{"1.0", "==", "1", true},
+		{"2", "!=", "2", false},
+		{"9", "<", "10", true},
+		{"10", "<=", "10", true},
+		{"10", ">", "9", true},
+		{"9", ">=", "10", false},
+		{"idle", "==", "idle", true},
+		{"idle", "!=", "busy", true},
+		{"9", "<", "a10", true},
+		{"b", ">", "a", true},
+		{"a", "=~", "a", false},
+		{"1", "=~", "1", false},
+	}
+	for _, tt := range tests {
+		if got := compare(tt.actual, tt.op, tt.expect); got != tt.want {
+			t.Errorf("compare(%s %s %s) = %v, want %v", tt.actual, tt.op, tt.expect, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package smooth
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// patchOperation one operation of a json patch
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// EnterMutateProcess inject readiness gate, preStop and sidecars of the matching Smooth into created pods.
+// Pods are never denied, errors only skip the injection
+func (sm *SmoothManager) EnterMutateProcess(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Kind != "Pod" || req.Operation != admissionv1.Create {
+		return returnMutateResponse(nil, "SKIP: KIND["+req.Kind.Kind+"] OPERATION["+string(req.Operation)+"]")
+	}
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		glog.Errorf("FAILURE: POD[%v], Unmarshal[false]: %v", req.Namespace+"/"+req.Name, err)
+		return returnMutateResponse(nil, "FAILURE: POD Unmarshal["+err.Error()+"]")
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+
+	smConfig, err := sm.GetSmoothConfig(pod)
+	if err != nil {
+		glog.Errorf("FAILURE: Get SmoothConfig[%s/%s%s]: %v", pod.Namespace, pod.GenerateName, pod.Name, err)
+		return returnMutateResponse(nil, "FAILURE: Get SmoothConfig["+err.Error()+"]")
+	}
+	if smConfig == nil || smConfig.Spec.Inject == nil {
+		return returnMutateResponse(nil, "{Smooth Inject NOT SET}")
+	}
+
+	patch := injectPatch(pod, smConfig.Spec.Inject)
+	if len(patch) == 0 {
+		return returnMutateResponse(nil, "{Smooth Inject applied}")
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return returnMutateResponse(nil, "FAILURE: Patch Marshal["+err.Error()+"]")
+	}
+	glog.Infof("SUCCESS: Inject POD[%s/%s%s] Smooth[%s] Patch[%s]", pod.Namespace, pod.GenerateName, pod.Name, smoothRecordName(smConfig), string(data))
+	return returnMutateResponse(data, "{Smooth["+smoothRecordName(smConfig)+"] injected}")
+}
+
+// injectPatch json patch of the changes inject asks for and pod lacks
+func injectPatch(pod corev1.Pod, inject *v1alpha1.Inject) []patchOperation {
+	var patch []patchOperation
+
+	if inject.ReadinessGate && !hasReadinessGate(&pod) {
+		gate := corev1.PodReadinessGate{ConditionType: v1alpha1.ReadinessGateType}
+		if len(pod.Spec.ReadinessGates) == 0 {
+			patch = append(patch, patchOperation{Op: "add", Path: "/spec/readinessGates", Value: []corev1.PodReadinessGate{gate}})
+		} else {
+			patch = append(patch, patchOperation{Op: "add", Path: "/spec/readinessGates/-", Value: gate})
+		}
+	}
+
+	if inject.PreStop != nil {
+		for i, container := range pod.Spec.Containers {
+			if len(inject.Containers) > 0 && !containsString(inject.Containers, container.Name) {
+				continue
+			}
+			path := "/spec/containers/" + strconv.Itoa(i) + "/lifecycle"
+			switch {
+			case container.Lifecycle == nil:
+				patch = append(patch, patchOperation{Op: "add", Path: path, Value: corev1.Lifecycle{PreStop: inject.PreStop}})
+			case container.Lifecycle.PreStop == nil:
+				patch = append(patch, patchOperation{Op: "add", Path: path + "/preStop", Value: inject.PreStop})
+			}
+		}
+	}
+
+	for _, sidecar := range inject.Sidecars {
+		exists := false
+		for _, container := range pod.Spec.Containers {
+			if container.Name == sidecar.Name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			patch = append(patch, patchOperation{Op: "add", Path: "/spec/containers/-", Value: sidecar})
+		}
+	}
+	return patch
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func returnMutateResponse(patch []byte, reason string) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{
+		Allowed: true,
+		Result: &metav1.Status{
+			Reason: metav1.StatusReason(reason),
+		},
+	}
+	if len(patch) > 0 {
+		patchType := admissionv1.PatchTypeJSONPatch
+		resp.Patch = patch
+		resp.PatchType = &patchType
+	}
+	return resp
+}
@@ -0,0 +1,60 @@
+package smooth
+
+import (
+	"strconv"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// VerifyDeletePodDisruptionBudget cap smoothing pods that are still Ready at the lowest disruptionsAllowed of the PDBs selecting pod,
+// the PDB controller already took unready ones out of disruptionsAllowed
+func (sm *SmoothManager) VerifyDeletePodDisruptionBudget(pod corev1.Pod) (bool, string) {
+	pdbs, err := sm.ClientKubeSet.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(sm.Ctx, metav1.ListOptions{})
+	if err != nil {
+		glog.Errorf("FAILURE: List PodDisruptionBudget[%v]", err)
+		return false, "PodDisruptionBudget LIST[" + err.Error() + "]"
+	}
+
+	var namePDB string
+	var countAllowed int
+	for _, pdb := range pdbs.Items {
+		// a nil selector selects no pods in policy/v1
+		if pdb.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			glog.Errorf("FAILURE: PodDisruptionBudget[%s/%s] Selector[%v]", pod.Namespace, pdb.Name, err)
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if namePDB == "" || int(pdb.Status.DisruptionsAllowed) < countAllowed {
+			namePDB = pdb.Name
+			countAllowed = int(pdb.Status.DisruptionsAllowed)
+		}
+	}
+	if namePDB == "" {
+		return true, ""
+	}
+	// an unready pod is already out of disruptionsAllowed, deleting it disrupts nothing more
+	if !podReady(&pod) {
+		return true, "PodDisruptionBudget[" + namePDB + "] pod not ready"
+	}
+	countReady, err := sm.CountReadySmoothingPods(pod)
+	if err != nil {
+		glog.Errorf("FAILURE: Count Ready Smoothing Pods[%s/%s], %v", pod.Namespace, pod.Name, err)
+		return false, "Pod GET[" + err.Error() + "]"
+	}
+	glog.Infof("MESSAGE: PodDisruptionBudget[%s/%s] SmoothReady[%v] DisruptionsAllowed[%v]", pod.Namespace, namePDB, countReady, countAllowed)
+
+	//平滑中仍就绪的副本数大于等于PDB允许中断数时，拒绝删除
+	if countReady >= countAllowed {
+		return false, "PodDisruptionBudget[" + namePDB + "] exceed disruptionsAllowed[" + strconv.Itoa(countReady) + "/" + strconv.Itoa(countAllowed) + "]"
+	}
+	return true, "PodDisruptionBudget[" + namePDB + "] disruptionsAllowed[" + strconv.Itoa(countReady) + "/" + strconv.Itoa(countAllowed) + "]"
+}
@@ -0,0 +1,110 @@
+package smooth
+
+import (
+	"context"
+	"testing"
+
+	"admitee/pkg/model"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testPod(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            name,
+			Labels:          map[string]string{"app": "web"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9f"}},
+		},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+	}
+}
+
+func TestVerifyDeletePodDisruptionBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   int32
+		self      *corev1.Pod
+		smoothing []*corev1.Pod
+		want      bool
+		reason    string
+	}{
+		{
+			name:      "unready smoothing pods are already out of disruptionsAllowed",
+			allowed:   1,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-1", false), testPod("web-2", false)},
+			want:      true,
+			reason:    "PodDisruptionBudget[web] disruptionsAllowed[0/1]",
+		},
+		{
+			name:      "ready smoothing pod uses the budget",
+			allowed:   1,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-1", true), testPod("web-2", false)},
+			want:      false,
+			reason:    "PodDisruptionBudget[web] exceed disruptionsAllowed[1/1]",
+		},
+		{
+			name:      "pod itself is not counted",
+			allowed:   1,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-0", true)},
+			want:      true,
+			reason:    "PodDisruptionBudget[web] disruptionsAllowed[0/1]",
+		},
+		{
+			name:    "unready pod disrupts nothing more",
+			allowed: 0,
+			self:    testPod("web-0", false),
+			want:    true,
+			reason:  "PodDisruptionBudget[web] pod not ready",
+		},
+		{
+			name:    "no disruptions allowed",
+			allowed: 0,
+			self:    testPod("web-0", true),
+			want:    false,
+			reason:  "PodDisruptionBudget[web] exceed disruptionsAllowed[0/0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdb := &policyv1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+				Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: tt.allowed},
+			}
+			objects := []runtime.Object{pdb}
+			store := model.NewMemoryStore()
+			for _, pod := range tt.smoothing {
+				if pod.Name != tt.self.Name {
+					objects = append(objects, pod)
+				}
+				if err := model.SetPodRecord(store, "default", pod.Name, &model.PodRecord{Namespace: "default", OwnerName: "web-7d9f"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			objects = append(objects, tt.self)
+			sm := &SmoothManager{ClientKubeSet: fake.NewSimpleClientset(objects...), Store: store, Ctx: context.Background()}
+
+			allowed, reason := sm.VerifyDeletePodDisruptionBudget(*tt.self)
+			if allowed != tt.want {
+				t.Errorf("allowed = %v, want %v, reason %s", allowed, tt.want, reason)
+			}
+			if reason != tt.reason {
+				t.Errorf("reason = %s, want %s", reason, tt.reason)
+			}
+		})
+	}
+}
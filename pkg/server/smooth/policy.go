@@ -0,0 +1,139 @@
+package smooth
+
+import (
+	"fmt"
+	"strconv"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// VerifyDeletePodPolicy apply maxConcurrent and minAvailable of the Smooth against ready replicas of the target
+func (sm *SmoothManager) VerifyDeletePodPolicy(pod corev1.Pod, smConfig *v1alpha1.Smooth, countUpdate int) (bool, string) {
+	if smConfig == nil || (smConfig.Spec.MaxConcurrent == nil && smConfig.Spec.MinAvailable == nil) {
+		return true, ""
+	}
+	kindTarget, nameTarget := smConfig.Spec.TargetRef.Kind, smConfig.Spec.TargetRef.Name
+	if !hasTargetRef(*smConfig) {
+		// selector Smooths throttle per workload of the pod
+		var err error
+		if kindTarget, nameTarget, err = sm.GetTarget(pod); err != nil {
+			return false, "Target GET[" + err.Error() + "]"
+		}
+	}
+	replicas, ready, err := sm.GetTargetReplicas(pod.Namespace, kindTarget, nameTarget)
+	if err != nil {
+		glog.Errorf("FAILURE: Get Target Replicas[%s/%s/%s], %v", pod.Namespace, kindTarget, nameTarget, err)
+		return false, kindTarget + " GET[" + err.Error() + "]"
+	}
+
+	var reason string
+	if smConfig.Spec.MaxConcurrent != nil {
+		countMax, err := intstr.GetScaledValueFromIntOrPercent(smConfig.Spec.MaxConcurrent, ready, false)
+		if err != nil {
+			return false, "Smooth MaxConcurrent[" + err.Error() + "]"
+		}
+		if countMax < 1 {
+			countMax = 1
+		}
+		glog.Infof("MESSAGE: Smooth[%s/%s] SmoothCount[%v] MaxConcurrentCount[%v] Ready[%v] MaxConcurrent[%v]", pod.Namespace, smConfig.Name, countUpdate, countMax, ready, smConfig.Spec.MaxConcurrent.String())
+		if countUpdate >= countMax {
+			return false, "Smooth[" + smoothRecordName(smConfig) + "] exceed maxConcurrent[" + strconv.Itoa(countUpdate) + "/" + strconv.Itoa(countMax) + "]"
+		}
+		reason = "Smooth[" + smoothRecordName(smConfig) + "] maxConcurrent[" + strconv.Itoa(countUpdate) + "/" + strconv.Itoa(countMax) + "]"
+	}
+	if smConfig.Spec.MinAvailable != nil {
+		countMin, err := intstr.GetScaledValueFromIntOrPercent(smConfig.Spec.MinAvailable, replicas, true)
+		if err != nil {
+			return false, "Smooth MinAvailable[" + err.Error() + "]"
+		}
+		// unready smoothing pods are already out of ready, the ones still Ready and the deleting one are leaving
+		countReady, err := sm.CountReadySmoothingPods(pod)
+		if err != nil {
+			glog.Errorf("FAILURE: Count Ready Smoothing Pods[%s/%s], %v", pod.Namespace, pod.Name, err)
+			return false, "Pod GET[" + err.Error() + "]"
+		}
+		countAvailable := ready - countReady
+		if podReady(&pod) {
+			countAvailable--
+		}
+		glog.Infof("MESSAGE: Smooth[%s/%s] SmoothCount[%v] SmoothReady[%v] MinAvailableCount[%v] Ready[%v] Replicas[%v] MinAvailable[%v]", pod.Namespace, smConfig.Name, countUpdate, countReady, countMin, ready, replicas, smConfig.Spec.MinAvailable.String())
+		if countAvailable < countMin {
+			return false, "Smooth[" + smoothRecordName(smConfig) + "] below minAvailable[" + strconv.Itoa(countAvailable) + "/" + strconv.Itoa(countMin) + "]"
+		}
+		reason = "Smooth[" + smoothRecordName(smConfig) + "] minAvailable[" + strconv.Itoa(countAvailable) + "/" + strconv.Itoa(countMin) + "]"
+	}
+	return true, reason
+}
+
+// ValidatePolicy maxConcurrent and minAvailable are non-negative ints or percents, timeout and timeoutPolicy are valid
+func ValidatePolicy(spec v1alpha1.SmoothSpec) error {
+	if _, err := ParseTimeout(spec.Timeout); err != nil {
+		return err
+	}
+	switch spec.TimeoutPolicy {
+	case "", v1alpha1.TimeoutPolicyAllow, v1alpha1.TimeoutPolicyDeny, v1alpha1.TimeoutPolicyDelete:
+	default:
+		return fmt.Errorf("timeoutPolicy[%s] must be one of Allow, Deny or Delete", spec.TimeoutPolicy)
+	}
+	switch spec.FailurePolicy {
+	case "", v1alpha1.FailurePolicyFail, v1alpha1.FailurePolicyIgnore:
+	default:
+		return fmt.Errorf("failurePolicy[%s] must be one of Fail or Ignore", spec.FailurePolicy)
+	}
+	for name, value := range map[string]*intstr.IntOrString{"maxConcurrent": spec.MaxConcurrent, "minAvailable": spec.MinAvailable} {
+		if value == nil {
+			continue
+		}
+		count, err := intstr.GetScaledValueFromIntOrPercent(value, 100, false)
+		if err != nil {
+			return fmt.Errorf("%s[%s]: %v", name, value.String(), err)
+		}
+		if count < 0 {
+			return fmt.Errorf("%s[%s] must not be negative", name, value.String())
+		}
+	}
+	return nil
+}
+
+// GetTargetReplicas desired and ready replicas of the target, percentages of minAvailable scale on desired
+func (sm *SmoothManager) GetTargetReplicas(namespace string, kind string, name string) (int, int, error) {
+	switch kind {
+	case "Deployment":
+		dp, err := sm.ClientKubeSet.AppsV1().Deployments(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(replicasOrOne(dp.Spec.Replicas)), int(dp.Status.ReadyReplicas), nil
+	case "ReplicaSet":
+		rs, err := sm.ClientKubeSet.AppsV1().ReplicaSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(replicasOrOne(rs.Spec.Replicas)), int(rs.Status.ReadyReplicas), nil
+	case "DaemonSet":
+		ds, err := sm.ClientKubeSet.AppsV1().DaemonSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(ds.Status.DesiredNumberScheduled), int(ds.Status.NumberReady), nil
+	case "StatefulSet":
+		sts, err := sm.ClientKubeSet.AppsV1().StatefulSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(replicasOrOne(sts.Spec.Replicas)), int(sts.Status.ReadyReplicas), nil
+	}
+	return 0, 0, fmt.Errorf("Kind NOT SUPPORTED[%s]", kind)
+}
+
+func replicasOrOne(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,97 @@
+package smooth
+
+import (
+	"context"
+	"testing"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/model"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVerifyDeletePodPolicyMinAvailable(t *testing.T) {
+	tests := []struct {
+		name      string
+		ready     int32
+		self      *corev1.Pod
+		smoothing []*corev1.Pod
+		want      bool
+		reason    string
+	}{
+		{
+			name:      "unready smoothing pods already out of ready",
+			ready:     3,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-1", false)},
+			want:      true,
+			reason:    "Smooth[web] minAvailable[2/2]",
+		},
+		{
+			name:      "smoothing pods still Ready are leaving",
+			ready:     4,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-1", true), testPod("web-2", false)},
+			want:      true,
+			reason:    "Smooth[web] minAvailable[2/2]",
+		},
+		{
+			name:      "smoothing pod still Ready below minAvailable",
+			ready:     3,
+			self:      testPod("web-0", true),
+			smoothing: []*corev1.Pod{testPod("web-1", true)},
+			want:      false,
+			reason:    "Smooth[web] below minAvailable[1/2]",
+		},
+		{
+			name:      "unready pod itself is already out",
+			ready:     2,
+			self:      testPod("web-0", false),
+			smoothing: []*corev1.Pod{testPod("web-0", false)},
+			want:      true,
+			reason:    "Smooth[web] minAvailable[2/2]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dp := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(4)},
+				Status:     appsv1.DeploymentStatus{ReadyReplicas: tt.ready},
+			}
+			objects := []runtime.Object{dp}
+			store := model.NewMemoryStore()
+			for _, pod := range tt.smoothing {
+				if pod.Name != tt.self.Name {
+					objects = append(objects, pod)
+				}
+				if err := model.SetPodRecord(store, "default", pod.Name, &model.PodRecord{Namespace: "default", OwnerName: "web-7d9f"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			objects = append(objects, tt.self)
+			sm := &SmoothManager{ClientKubeSet: fake.NewSimpleClientset(objects...), Store: store, Ctx: context.Background()}
+
+			minAvailable := intstr.FromString("50%")
+			smConfig := &v1alpha1.Smooth{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       v1alpha1.SmoothSpec{MinAvailable: &minAvailable},
+			}
+			smConfig.Spec.TargetRef.Kind, smConfig.Spec.TargetRef.Name = "Deployment", "web"
+
+			allowed, reason := sm.VerifyDeletePodPolicy(*tt.self, smConfig, len(tt.smoothing))
+			if allowed != tt.want {
+				t.Errorf("allowed = %v, want %v, reason %s", allowed, tt.want, reason)
+			}
+			if reason != tt.reason {
+				t.Errorf("reason = %s, want %s", reason, tt.reason)
+			}
+		})
+	}
+}
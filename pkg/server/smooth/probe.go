@@ -0,0 +1,44 @@
+package smooth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/utils"
+)
+
+// ProbeRule send the request of rule, request errors are retried with backoff until ctx is done
+func ProbeRule(ctx context.Context, method string, url string, rule v1alpha1.Rule, tlsConfig *tls.Config) (*utils.RestApiResponse, error) {
+	timeout := time.Duration(utils.TimeOut) * time.Second
+	if rule.Timeout != nil && rule.Timeout.Duration > 0 {
+		timeout = rule.Timeout.Duration
+	}
+	backoff := time.Duration(v1alpha1.DefaultBackoff) * time.Second
+	if rule.Backoff != nil && rule.Backoff.Duration > 0 {
+		backoff = rule.Backoff.Duration
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := utils.RestApiRequest(attemptCtx, method, url, rule.Body, rule.Headers, tlsConfig)
+		cancel()
+		// without a status matcher only 200 is a response, as before
+		if err == nil && !HasStatusMatcher(rule) && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("FAILURE: Http status code[%v]", resp.StatusCode)
+		}
+		if err == nil || attempt >= rule.Retries {
+			return resp, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return resp, err
+		}
+		backoff *= 2
+	}
+}
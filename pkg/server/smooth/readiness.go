@@ -0,0 +1,70 @@
+package smooth
+
+import (
+	"encoding/json"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// readiness gate condition reasons
+const (
+	GateReasonServing   = "Serving"
+	GateReasonSmoothing = "Smoothing"
+	GateReasonAborted   = "SmoothAborted"
+	GateReasonTimeout   = "SmoothTimeout"
+)
+
+// hasReadinessGate pod was created with the readiness gate of admiteed
+func hasReadinessGate(pod *corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == v1alpha1.ReadinessGateType {
+			return true
+		}
+	}
+	return false
+}
+
+// readinessGateStatus status of the gate condition, empty when not set yet
+func readinessGateStatus(pod *corev1.Pod) corev1.ConditionStatus {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1alpha1.ReadinessGateType {
+			return condition.Status
+		}
+	}
+	return ""
+}
+
+// SetReadinessGate patch the gate condition of pod, pods without the gate are left alone
+func (sm *SmoothManager) SetReadinessGate(pod corev1.Pod, status corev1.ConditionStatus, reason string, message string) error {
+	if !hasReadinessGate(&pod) || readinessGateStatus(&pod) == status {
+		return nil
+	}
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.PodCondition{{
+				Type:               v1alpha1.ReadinessGateType,
+				Status:             status,
+				LastTransitionTime: metav1.NewTime(time.Now()),
+				Reason:             reason,
+				Message:            message,
+			}},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Patch(sm.Ctx, pod.Name, types.StrategicMergePatchType, data, metav1.PatchOptions{}, "status")
+	if err != nil {
+		glog.Errorf("FAILURE: ReadinessGate[%s/%s] Status[%s]: %v", pod.Namespace, pod.Name, status, err)
+		return err
+	}
+	glog.Infof("SUCCESS: ReadinessGate[%s/%s] Status[%s] Reason[%s]", pod.Namespace, pod.Name, status, reason)
+	return nil
+}
@@ -0,0 +1,164 @@
+package smooth
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"admitee/pkg/api/v1alpha1"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// targetKindSelector metric label of Smooths targeting pods by selector
+const targetKindSelector = "Selector"
+
+// ListSmooths every Smooth in namespace
+func (sm *SmoothManager) ListSmooths(namespace string) ([]v1alpha1.Smooth, error) {
+	list, err := sm.ClientSmooth.Resource(smoothGVR).Namespace(namespace).List(sm.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := list.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var smList v1alpha1.SmoothList
+	if err := json.Unmarshal(data, &smList); err != nil {
+		return nil, err
+	}
+	return smList.Items, nil
+}
+
+// SmoothMatchesPod targetRef matches the target of pod, or selector matches the labels of pod
+func SmoothMatchesPod(smooth v1alpha1.Smooth, pod corev1.Pod, kindTarget string, nameTarget string) bool {
+	if hasTargetRef(smooth) {
+		return smooth.Spec.TargetRef.Kind == kindTarget && smooth.Spec.TargetRef.Name == nameTarget
+	}
+	if smooth.Spec.Selector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(smooth.Spec.Selector)
+	if err != nil {
+		glog.Errorf("FAILURE: Smooth[%s/%s] Selector[%v]", smooth.Namespace, smooth.Name, err)
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+// ResolveSmooth the Smooth applied to pod and every Smooth matching it, ordered by
+// targetRef before selector, higher priority, more specific selector, then name
+func ResolveSmooth(smooths []v1alpha1.Smooth, pod corev1.Pod, kindTarget string, nameTarget string) (*v1alpha1.Smooth, []v1alpha1.Smooth) {
+	var matched []v1alpha1.Smooth
+	for _, smooth := range smooths {
+		if SmoothMatchesPod(smooth, pod, kindTarget, nameTarget) {
+			matched = append(matched, smooth)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return smoothPrecedes(matched[i], matched[j])
+	})
+	return &matched[0], matched
+}
+
+func smoothPrecedes(a v1alpha1.Smooth, b v1alpha1.Smooth) bool {
+	if hasTargetRef(a) != hasTargetRef(b) {
+		return hasTargetRef(a)
+	}
+	if a.Spec.Priority != b.Spec.Priority {
+		return a.Spec.Priority > b.Spec.Priority
+	}
+	if selectorSpecificity(a) != selectorSpecificity(b) {
+		return selectorSpecificity(a) > selectorSpecificity(b)
+	}
+	return a.Name < b.Name
+}
+
+func hasTargetRef(smooth v1alpha1.Smooth) bool {
+	return smooth.Spec.TargetRef.Name != ""
+}
+
+func selectorSpecificity(smooth v1alpha1.Smooth) int {
+	if smooth.Spec.Selector == nil {
+		return 0
+	}
+	return len(smooth.Spec.Selector.MatchLabels) + len(smooth.Spec.Selector.MatchExpressions)
+}
+
+// smoothTargetLabels target labels of the smoothing pods gauge, selector Smooths are labeled by their own name
+func smoothTargetLabels(smooth v1alpha1.Smooth) (string, string) {
+	if hasTargetRef(smooth) {
+		return smooth.Spec.TargetRef.Kind, smooth.Spec.TargetRef.Name
+	}
+	return targetKindSelector, smooth.Name
+}
+
+// SmoothConflict condition of smooth, True when another Smooth also matches one of its pods
+func (sm *SmoothManager) SmoothConflict(smooth *v1alpha1.Smooth, podLister corelisters.PodLister) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionConflict,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: smooth.Generation,
+		Reason:             "NoConflict",
+	}
+	// conflicts are resolved per pod, only selector Smooths list their pods cheaply
+	if hasTargetRef(*smooth) || smooth.Spec.Selector == nil {
+		return condition
+	}
+	selector, err := metav1.LabelSelectorAsSelector(smooth.Spec.Selector)
+	if err != nil {
+		return condition
+	}
+	pods, err := podLister.Pods(smooth.Namespace).List(selector)
+	if err != nil || len(pods) == 0 {
+		return condition
+	}
+	smooths, err := sm.ListSmooths(smooth.Namespace)
+	if err != nil {
+		glog.Errorf("FAILURE: List Smooth[%s]: %v", smooth.Namespace, err)
+		return condition
+	}
+
+	var overriddenBy, overrides []string
+	for _, pod := range pods {
+		kindTarget, nameTarget, _ := sm.GetTarget(*pod)
+		winner, matched := ResolveSmooth(smooths, *pod, kindTarget, nameTarget)
+		if len(matched) < 2 {
+			continue
+		}
+		if winner.Name != smooth.Name {
+			overriddenBy = appendUnique(overriddenBy, winner.Name)
+			continue
+		}
+		for _, other := range matched[1:] {
+			overrides = appendUnique(overrides, other.Name)
+		}
+	}
+	switch {
+	case len(overriddenBy) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Overridden"
+		condition.Message = "pods applied Smooth[" + strings.Join(overriddenBy, ",") + "]"
+	case len(overrides) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Preferred"
+		condition.Message = "preferred over Smooth[" + strings.Join(overrides, ",") + "]"
+	}
+	return condition
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,151 @@
+package smooth
+
+import (
+	"reflect"
+	"testing"
+
+	"admitee/pkg/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func selectorSmooth(name string, priority int, matchLabels map[string]string) v1alpha1.Smooth {
+	return v1alpha1.Smooth{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: v1alpha1.SmoothSpec{
+			Priority: priority,
+			Selector: &metav1.LabelSelector{MatchLabels: matchLabels},
+		},
+	}
+}
+
+func targetSmooth(name string, priority int, kind string, target string) v1alpha1.Smooth {
+	smooth := v1alpha1.Smooth{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1alpha1.SmoothSpec{Priority: priority},
+	}
+	smooth.Spec.TargetRef.Kind, smooth.Spec.TargetRef.Name = kind, target
+	return smooth
+}
+
+func TestResolveSmooth(t *testing.T) {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "web-7d9f-x2k",
+		Labels:    map[string]string{"app": "web", "tier": "frontend", "track": "stable"},
+	}}
+
+	tests := []struct {
+		name    string
+		smooths []v1alpha1.Smooth
+		want    string
+		matched []string
+	}{
+		{
+			name:    "nothing matches",
+			smooths: []v1alpha1.Smooth{selectorSmooth("api", 0, map[string]string{"app": "api"}), targetSmooth("other", 0, "Deployment", "api")},
+		},
+		{
+			name: "selector without targetRef and nil selector",
+			smooths: []v1alpha1.Smooth{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "empty"}},
+				selectorSmooth("web", 0, map[string]string{"app": "web"}),
+			},
+			want:    "web",
+			matched: []string{"web"},
+		},
+		{
+			name: "targetRef wins over higher priority selector",
+			smooths: []v1alpha1.Smooth{
+				selectorSmooth("all-web", 100, map[string]string{"app": "web"}),
+				targetSmooth("web", 0, "Deployment", "web"),
+			},
+			want:    "web",
+			matched: []string{"web", "all-web"},
+		},
+		{
+			name: "targetRef of another kind does not match",
+			smooths: []v1alpha1.Smooth{
+				targetSmooth("web-sts", 0, "StatefulSet", "web"),
+				selectorSmooth("all-web", 0, map[string]string{"app": "web"}),
+			},
+			want:    "all-web",
+			matched: []string{"all-web"},
+		},
+		{
+			name: "overlapping selectors ordered by priority",
+			smooths: []v1alpha1.Smooth{
+				selectorSmooth("frontend", 5, map[string]string{"tier": "frontend", "track": "stable"}),
+				selectorSmooth("web", 10, map[string]string{"app": "web"}),
+				selectorSmooth("stable", -1, map[string]string{"track": "stable"}),
+			},
+			want:    "web",
+			matched: []string{"web", "frontend", "stable"},
+		},
+		{
+			name: "equal priority more specific selector first",
+			smooths: []v1alpha1.Smooth{
+				selectorSmooth("web", 0, map[string]string{"app": "web"}),
+				selectorSmooth("web-frontend-stable", 0, map[string]string{"app": "web", "tier": "frontend", "track": "stable"}),
+				selectorSmooth("web-frontend", 0, map[string]string{"app": "web", "tier": "frontend"}),
+			},
+			want:    "web-frontend-stable",
+			matched: []string{"web-frontend-stable", "web-frontend", "web"},
+		},
+		{
+			name: "name breaks ties",
+			smooths: []v1alpha1.Smooth{
+				selectorSmooth("web-b", 0, map[string]string{"app": "web"}),
+				selectorSmooth("web-a", 0, map[string]string{"tier": "frontend"}),
+			},
+			want:    "web-a",
+			matched: []string{"web-a", "web-b"},
+		},
+		{
+			name: "selector that does not match is left out",
+			smooths: []v1alpha1.Smooth{
+				selectorSmooth("canary", 50, map[string]string{"app": "web", "track": "canary"}),
+				selectorSmooth("web", 0, map[string]string{"app": "web"}),
+			},
+			want:    "web",
+			matched: []string{"web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := ResolveSmooth(tt.smooths, pod, "Deployment", "web")
+			if tt.want == "" {
+				if got != nil || matched != nil {
+					t.Fatalf("ResolveSmooth = %v, %v, want nil", got, matched)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Fatalf("ResolveSmooth = %v, want %s", got, tt.want)
+			}
+			var names []string
+			for _, smooth := range matched {
+				names = append(names, smooth.Name)
+			}
+			if !reflect.DeepEqual(names, tt.matched) {
+				t.Errorf("matched = %v, want %v", names, tt.matched)
+			}
+		})
+	}
+}
+
+func TestResolveSmoothKeepsInput(t *testing.T) {
+	smooths := []v1alpha1.Smooth{
+		selectorSmooth("web", 0, map[string]string{"app": "web"}),
+		selectorSmooth("web-priority", 10, map[string]string{"app": "web"}),
+	}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}
+	if got, _ := ResolveSmooth(smooths, pod, "Deployment", "web"); got == nil || got.Name != "web-priority" {
+		t.Fatalf("ResolveSmooth = %v, want web-priority", got)
+	}
+	if smooths[0].Name != "web" {
+		t.Errorf("ResolveSmooth reordered the listed Smooths to %s first", smooths[0].Name)
+	}
+}
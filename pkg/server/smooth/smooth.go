@@ -2,27 +2,31 @@ package smooth
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/metrics"
 	"admitee/pkg/model"
 	"admitee/pkg/utils"
 
 	"github.com/golang/glog"
-	"k8s.io/api/admission/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/apis/core/v1"
 )
 
@@ -35,12 +39,43 @@ var (
 	podconf   = make(map[string]bool)
 )
 
+// decisions exported as reason of admitee_admission_decisions_total, keep the set small
+const (
+	decisionTerminating      = "terminating"
+	decisionPodPhase         = "pod_phase"
+	decisionTargetError      = "target_error"
+	decisionStoreError       = "store_error"
+	decisionBudgetExceeded   = "budget_exceeded"
+	decisionConfigError      = "config_error"
+	decisionNoConfig         = "no_config"
+	decisionInvalidRule      = "invalid_rule"
+	decisionDeadline         = "deadline_exceeded"
+	decisionPending          = "rules_pending"
+	decisionRuleUnexpected   = "rule_unexpected"
+	decisionLabelError       = "label_error"
+	decisionPodReady         = "pod_ready"
+	decisionRulesPassed      = "rules_passed"
+	decisionTimeout          = "timeout"
+	decisionLockTimeout      = "lock_timeout"
+	decisionStoreUnavailable = "store_unavailable"
+	decisionAPIUnavailable   = "apiserver_unavailable"
+)
+
 type SmoothManager struct {
 	Config        v1alpha1.Smooth
-	ClientRedis   *model.AdmiteeRedisClient
+	Store         model.Store
 	ClientSmooth  dynamic.Interface
-	ClientKubeSet *kubernetes.Clientset
+	ClientKubeSet kubernetes.Interface
 	Ctx           context.Context
+	Recorder      record.EventRecorder
+	// Deadline of an admission request, rules are cut short before the apiserver gives up on the webhook
+	Deadline time.Duration
+	// Evaluation default of Smooths without evaluation, Sync or Async
+	Evaluation string
+	// FailurePolicy default of Smooths without failurePolicy, Fail or Ignore
+	FailurePolicy string
+	// LockTimeout bounds the wait for the lock of a target, 0 waits as long as the request lives
+	LockTimeout time.Duration
 }
 
 func init() {
@@ -52,16 +87,39 @@ func init() {
 }
 
 // ValidatingAdmissionWebhook
-func (sm *SmoothManager) EnterSmoothProcess(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
-	req := ar.Request
+func (sm *SmoothManager) EnterSmoothProcess(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
 	var allowed bool
 
+	if sm.Deadline > 0 {
+		ctx, cancel := context.WithTimeout(sm.Ctx, sm.Deadline)
+		defer cancel()
+		sm.Ctx = ctx
+	}
+
+	//驱逐请求，按删除POD处理
+	if req.Resource.Resource == "pods" && req.SubResource == "eviction" {
+		if req.Operation != admissionv1.Create {
+			return returnAdmissionResponse(allowed, "FAILURE: OPERATION["+string(req.Operation)+"]")
+		}
+		pod, err := sm.GetEvictionPod(req)
+		if err != nil {
+			glog.Errorf("FAILURE: Eviction[%v], Resource[%v]: %v", req.Namespace+"/"+req.Name, req.Kind, err)
+			return returnEvictionResponse(allowed, "FAILURE: Eviction["+err.Error()+"]")
+		}
+		if req.DryRun != nil && *req.DryRun {
+			allowed, reason, _ := sm.DryRunPod(pod)
+			return returnEvictionResponse(allowed, reason)
+		}
+		allowed, reason := sm.SmoothPod(pod, req.UserInfo.Username)
+		return returnEvictionResponse(allowed, reason)
+	}
+
 	//非POD请求，拒绝
 	if req.Kind.Kind != "Pod" {
 		return returnAdmissionResponse(allowed, "FAILURE: KIND["+req.Kind.Kind+"]")
 	}
 	//非删除操作，拒绝
-	if req.Operation != "DELETE" {
+	if req.Operation != admissionv1.Delete {
 		return returnAdmissionResponse(allowed, "FAILURE: OPERATION["+string(req.Operation)+"]")
 	}
 
@@ -72,200 +130,276 @@ func (sm *SmoothManager) EnterSmoothProcess(ar *v1beta1.AdmissionReview) *v1beta
 		return returnAdmissionResponse(allowed, "FAILURE: POD Unmarshal["+err.Error()+"]")
 	}
 
+	if req.DryRun != nil && *req.DryRun {
+		allowed, reason, _ := sm.DryRunPod(pod)
+		return returnAdmissionResponse(allowed, reason)
+	}
+	allowed, reason := sm.SmoothPod(pod, req.UserInfo.Username)
+	return returnAdmissionResponse(allowed, reason)
+}
+
+// SmoothPod runs budget and rule checks for a pod about to go away, shared by DELETE and eviction
+func (sm *SmoothManager) SmoothPod(pod corev1.Pod, requestedBy string) (bool, string) {
+	allowed, reason, decision := sm.smoothPod(pod, requestedBy)
+
+	var namespace = pod.Namespace
+	var namePod = pod.Name
+	record, _ := model.GetPodRecord(sm.Store, namespace, namePod)
+	kindTarget, _, _ := utils.GetOwnerReference(pod)
+	if record != nil && record.TargetKind != "" {
+		kindTarget = record.TargetKind
+	}
+	metrics.AdmissionDecisions.WithLabelValues(namespace, kindTarget, strconv.FormatBool(allowed), decision).Inc()
+
+	glog.Infof("MESSAGE: POD[%v],Delete[%v],Reason[%v]", namespace+"/"+namePod, allowed, reason)
+	if allowed {
+		vauleDelete, _ := sm.Store.Get(model.KindDelete, namespace, namePod)
+		if vauleDelete == "" {
+			value := "1"
+			ok, err := sm.Store.SetNX(model.KindDelete, namespace, namePod, value)
+			if err == nil && ok {
+				glog.Infof("SUCCESS: SET[%s:%s]", storeKey(model.KindDelete, namespace, namePod), value)
+				if record != nil {
+					metrics.SmoothingDuration.WithLabelValues(namespace, kindTarget).Observe(time.Since(record.FirstSeen).Seconds())
+					sm.Eventf(pod, record.SmoothName, corev1.EventTypeNormal, EventDeleteAllowed, "Delete allowed after %v, %s", time.Since(record.FirstSeen).Round(time.Second), reason)
+				}
+			}
+		}
+	}
+	return allowed, reason
+}
+
+// podPhaseDecision pods terminating, pending or failed are deleted without smoothing
+func podPhaseDecision(pod corev1.Pod) (bool, string, string) {
 	if pod.ObjectMeta.DeletionTimestamp != nil {
-		return returnAdmissionResponse(true, "{pod DeletionTimestamp not null}")
+		return true, "{pod DeletionTimestamp not null}", decisionTerminating
 	}
 
 	if pod.Status.Phase != "Running" {
 		switch pod.Status.Phase {
 		case "Pending":
-			return returnAdmissionResponse(true, "{pod status "+string(pod.Status.Phase)+"}")
+			return true, "{pod status " + string(pod.Status.Phase) + "}", decisionPodPhase
 		case "Failed":
-			return returnAdmissionResponse(true, "{pod status "+string(pod.Status.Phase)+"/"+string(pod.Status.Reason)+"}")
+			return true, "{pod status " + string(pod.Status.Phase) + "/" + string(pod.Status.Reason) + "}", decisionPodPhase
 		}
 	}
+	return false, "", ""
+}
+
+func (sm *SmoothManager) smoothPod(pod corev1.Pod, requestedBy string) (bool, string, string) {
+	var allowed bool
+
+	if ok, reason, decision := podPhaseDecision(pod); ok {
+		return ok, reason, decision
+	}
 
 	var namespace = pod.Namespace
 	var namePod = pod.Name
-	var reason string
-
-	var keyPOD = "ADMITEE_SMOOTH_POD_" + namespace + "_" + namePod
-	valuePOD, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, keyPOD).Result()
+	var reason, decision string
 
-	var keySmLabeled = "ADMITEE_SMOOTH_LABEL_" + namespace + "_" + namePod
-	valueSmLabeled, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, keySmLabeled).Result()
+	if !sm.Store.Healthy() {
+		return sm.failureResponse(pod, componentStore, fmt.Errorf("store unhealthy"))
+	}
+	valuePOD, err := sm.Store.Get(model.KindPod, namespace, namePod)
+	if err != nil {
+		return sm.failureResponse(pod, componentStore, err)
+	}
+	valueSmLabeled, err := sm.Store.Get(model.KindLabel, namespace, namePod)
+	if err != nil {
+		return sm.failureResponse(pod, componentStore, err)
+	}
 
 	if valuePOD != "" || valueSmLabeled != "" {
-		allowed, reason = sm.SmoothConfigExec(pod)
+		allowed, reason, decision = sm.SmoothConfigExec(pod, requestedBy)
 	} else {
 		// POD首次删除
 		_, _, err := sm.GetTarget(pod)
-		if err != nil {
+		if Unavailable(err) {
+			return sm.failureResponse(pod, componentAPIServer, err)
+		} else if err != nil {
 			glog.Errorf("FAILURE: Get Target[%s/%s], %v", namespace, namePod, err)
-			return returnAdmissionResponse(allowed, err.Error())
+			return allowed, err.Error(), decisionTargetError
 		}
 		// Lock this request
 		kindOwnerReference, nameOwnerReference, _ := utils.GetOwnerReference(pod)
 		key := "LOCK_" + kindOwnerReference + "_" + namespace + "_" + nameOwnerReference
+		lockStart := time.Now()
 		for {
-			boolLock := sm.ClientRedis.Lock(key)
-			if !boolLock {
-				time.Sleep(time.Duration(1) * time.Second)
-				continue
-			} else {
+			boolLock, err := sm.Store.Lock(key)
+			if err != nil {
+				metrics.LockWaitDuration.Observe(time.Since(lockStart).Seconds())
+				return sm.failureResponse(pod, componentStore, err)
+			}
+			if boolLock {
 				break
 			}
+			if (sm.LockTimeout > 0 && time.Since(lockStart) >= sm.LockTimeout) || sm.Ctx.Err() != nil {
+				metrics.LockWaitDuration.Observe(time.Since(lockStart).Seconds())
+				glog.Errorf("FAILURE: Lock[%s] wait timeout[%v]", key, time.Since(lockStart).Round(time.Millisecond))
+				return allowed, "{lock wait timeout[" + time.Since(lockStart).Round(time.Second).String() + "] " + key + "}", decisionLockTimeout
+			}
+			select {
+			case <-time.After(time.Duration(1) * time.Second):
+			case <-sm.Ctx.Done():
+			}
 		}
+		metrics.LockWaitDuration.Observe(time.Since(lockStart).Seconds())
 		glog.Infof("MESSAGE: Smoothing Target[%s] POD[%s]", namespace+"/"+kindOwnerReference+"/"+nameOwnerReference, namePod)
 
-		var boolPodDelete bool
 		// count smoothing pods
 		countUpdate, err := sm.CountSmoothingPodsByOwnerReferenceName(namespace, nameOwnerReference)
 		if err != nil {
-			return returnAdmissionResponse(allowed, err.Error())
-		}
-
-		if countUpdate < 1 || pod.Labels[v1alpha1.LabelForce] == "true" || pod.Labels[v1alpha1.LabelForce] == "1" {
-			boolPodDelete = true
-			glog.Infof("MESSAGE: Target[%s] Smoothing Count[0]", namespace+"/"+kindOwnerReference+"/"+nameOwnerReference)
-		} else {
-			//确定副本是否允许删除
-			switch kindOwnerReference {
-			case "DaemonSet":
-				boolPodDelete, reason = sm.VerifyDeletePodDaemonSet(namespace, nameOwnerReference, countUpdate)
-			case "ReplicaSet":
-				boolPodDelete, reason = sm.VerifyDeletePodReplicaSet(namespace, nameOwnerReference, countUpdate)
-			}
+			sm.Store.UnLock(key)
+			return sm.failureResponse(pod, componentStore, err)
 		}
 
+		var boolPodDelete bool
+		boolPodDelete, reason = sm.VerifyDeleteBudget(pod, countUpdate)
 		if boolPodDelete {
 			// 已存在POD记录，执行平滑过程
-			allowed, reason = sm.SmoothConfigExec(pod)
+			allowed, reason, decision = sm.SmoothConfigExec(pod, requestedBy)
+		} else {
+			decision = decisionBudgetExceeded
 		}
 		// Release the lock
-		unLock := sm.ClientRedis.UnLock(key)
+		unLock := sm.Store.UnLock(key)
 		if unLock != 1 {
 			glog.Errorf("FAILURE: UnLock[" + key + "]")
 		}
 	}
 
-	glog.Infof("MESSAGE: POD[%v],Delete[%v],Reason[%v]", req.Namespace+"/"+req.Name, allowed, reason)
-	if allowed {
-		key := "ADMITEE_SMOOTH_DEL_" + req.Namespace + "_" + req.Name
-		vauleDelete, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, key).Result()
-		if vauleDelete == "" {
-			value := "1"
-			err = sm.ClientRedis.Client.SetNX(sm.ClientRedis.Ctx, key, value, 0).Err()
-			if err == nil {
-				glog.Infof("SUCCESS: SET[%s:%s]", key, value)
-			}
-		}
-	}
-	return returnAdmissionResponse(allowed, reason)
+	return allowed, reason, decision
 }
 
-func (sm *SmoothManager) SmoothConfigExec(pod corev1.Pod) (bool, string) {
-	var keySmLabeled = "ADMITEE_SMOOTH_LABEL_" + pod.Namespace + "_" + pod.Name
-	valueSmLabeled, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, keySmLabeled).Result()
+// VerifyDeleteBudget rollout strategy, Smooth policy and PodDisruptionBudgets of pod with countUpdate pods smoothing,
+// the reason names the constraint denying
+func (sm *SmoothManager) VerifyDeleteBudget(pod corev1.Pod, countUpdate int) (bool, string) {
+	var boolPodDelete bool
+	var reason string
+	kindOwnerReference, nameOwnerReference, _ := utils.GetOwnerReference(pod)
+	force := pod.Labels[v1alpha1.LabelForce] == "true" || pod.Labels[v1alpha1.LabelForce] == "1"
+	if countUpdate < 1 || force {
+		glog.Infof("MESSAGE: Target[%s] Smoothing Count[%v]", pod.Namespace+"/"+kindOwnerReference+"/"+nameOwnerReference, countUpdate)
+		return true, ""
+	}
 
-	var smConfig *v1alpha1.Smooth
-	if valueSmLabeled != "" {
-		if err := json.Unmarshal([]byte(valueSmLabeled), &smConfig); err != nil {
-			return false, err.Error()
-		}
-	} else {
-		var err error
-		smConfig, err = sm.GetSmoothConfig(pod)
+	//确定副本是否允许删除
+	switch kindOwnerReference {
+	case "DaemonSet":
+		boolPodDelete, reason = sm.VerifyDeletePodDaemonSet(pod.Namespace, nameOwnerReference, countUpdate)
+	case "StatefulSet":
+		boolPodDelete, reason = sm.VerifyDeletePodStatefulSet(pod.Namespace, nameOwnerReference, pod.Name, countUpdate)
+	case "ReplicaSet":
+		boolPodDelete, reason = sm.VerifyDeletePodReplicaSet(pod.Namespace, nameOwnerReference, countUpdate)
+	}
+	// maxConcurrent and minAvailable of the Smooth throttle below the rollout strategy
+	if boolPodDelete {
+		smConfig, err := sm.GetSmoothConfig(pod)
 		if err != nil {
-			return false, err.Error()
+			glog.Errorf("FAILURE: Get SmoothConfig[%s/%s], %v", pod.Namespace, pod.Name, err)
+		}
+		var reasonPolicy string
+		boolPodDelete, reasonPolicy = sm.VerifyDeletePodPolicy(pod, smConfig, countUpdate)
+		if !boolPodDelete {
+			reason = reasonPolicy
 		}
 	}
+	// PodDisruptionBudgets covering the pod cap the budget as well
+	if boolPodDelete {
+		var reasonPDB string
+		boolPodDelete, reasonPDB = sm.VerifyDeletePodDisruptionBudget(pod)
+		if !boolPodDelete {
+			reason = reasonPDB
+		}
+	}
+	return boolPodDelete, reason
+}
+
+// SmoothConfigExec probes the rules of the Smooth of pod, returns allowed, reason and decision
+func (sm *SmoothManager) SmoothConfigExec(pod corev1.Pod, requestedBy string) (bool, string, string) {
+	smConfig, smLabeled, err := sm.PodSmoothConfig(pod)
+	if Unavailable(err) {
+		return sm.failureResponse(pod, componentAPIServer, err)
+	} else if err != nil {
+		return false, err.Error(), decisionConfigError
+	}
 
 	if smConfig == nil {
-		return true, fmt.Sprintf("Smooth Config NOT SET[%s/%s]", pod.Namespace, pod.Name)
+		return true, fmt.Sprintf("Smooth Config NOT SET[%s/%s]", pod.Namespace, pod.Name), decisionNoConfig
 	}
 
-	var interval, timeout int
-	_, err := sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Get(sm.Ctx, pod.Name, metav1.GetOptions{})
+	var interval int
+	_, err = sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Get(sm.Ctx, pod.Name, metav1.GetOptions{})
 	if err == nil {
 		if smConfig != nil && smConfig.Spec.Interval > 0 {
 			interval = smConfig.Spec.Interval
 		}
-		if smConfig != nil && smConfig.Spec.Timeout > 0 {
-			timeout = smConfig.Spec.Timeout
-		}
 	}
 
 	if interval == 0 {
 		interval = v1alpha1.DefaultInterval
 	}
-	if timeout == 0 {
-		timeout = v1alpha1.DefaultTimeout
-	}
+	timeout := SmoothTimeout(smConfig)
 
-	var keyPod = "ADMITEE_SMOOTH_POD_" + pod.Namespace + "_" + pod.Name
-	vaulePOD, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, keyPod).Result()
+	vaulePOD, err := sm.Store.Get(model.KindPod, pod.Namespace, pod.Name)
+	if err != nil {
+		return sm.failureResponse(pod, componentStore, err)
+	}
 	if vaulePOD == "" && len(pod.GetOwnerReferences()) == 1 {
-		value := pod.Namespace + "_" + pod.GetOwnerReferences()[0].Name + "_" + strconv.Itoa(interval) + "_" + strconv.Itoa(timeout) + "_" + strconv.FormatInt(time.Now().Unix(), 10) + "_0"
-		err := sm.ClientRedis.Client.SetNX(sm.ClientRedis.Ctx, keyPod, value, 0).Err()
-		if err == nil {
-			glog.Infof("SUCCESS: SET[%s:%s]", keyPod, value)
+		kindTarget, nameTarget, _ := sm.GetTarget(pod)
+		now := time.Now()
+		record := &model.PodRecord{
+			Namespace:   pod.Namespace,
+			OwnerName:   pod.GetOwnerReferences()[0].Name,
+			TargetKind:  kindTarget,
+			TargetName:  nameTarget,
+			SmoothName:  smoothRecordName(smConfig),
+			Interval:    interval,
+			FirstSeen:   now,
+			LastProbe:   now,
+			RequestedBy: requestedBy,
+
+			TimeoutSeconds: int(timeout.Seconds()),
+			TimeoutPolicy:  TimeoutPolicy(smConfig),
 		}
-	}
-
-	var allowed = true
-	var reasons []string
-	for _, rule := range smConfig.Spec.Rules {
-		if rule.Port >= 65535 {
-			glog.Errorf("FAILURE: Port OutOfRange 0~65535 [%v]", rule.Port)
-			return false, fmt.Sprintf("FAILURE: Port OutOfRange 0~65535 [%v]", rule.Port)
-		} else if rule.Port == 0 {
-			rule.Port = int(pod.Spec.Containers[0].Ports[0].ContainerPort)
-			if rule.Port == 0 {
-				rule.Port = v1alpha1.DefaultPort
+		ok, err := model.SetPodRecordNX(sm.Store, pod.Namespace, pod.Name, record)
+		if err == nil && ok {
+			glog.Infof("SUCCESS: SET[%s:%s/%s]", storeKey(model.KindPod, pod.Namespace, pod.Name), kindTarget, nameTarget)
+			sm.Eventf(pod, smoothRecordName(smConfig), corev1.EventTypeNormal, EventSmoothingStarted, "Smoothing started, target %s/%s, requested by %s", kindTarget, nameTarget, requestedBy)
+			//通知主副本的平滑控制器，记录本身只做长周期的兜底同步
+			patch := []byte(`{"metadata":{"annotations":{"` + v1alpha1.AnnotationSmoothing + `":"` + now.Format(time.RFC3339Nano) + `"}}}`)
+			if _, err := sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Patch(sm.Ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+				glog.Errorf("FAILURE: Annotate POD[%s/%s]: %v", pod.Namespace, pod.Name, err)
 			}
 		}
+	}
 
-		if rule.Path == "" {
-			return false, fmt.Sprintf("FAILURE: Path NOT SET[%v]", rule)
-		}
-
-		var url string
-		if rule.Address != "" {
-			url = "http://" + rule.Address + ":" + strconv.Itoa(rule.Port) + rule.Path
-		} else {
-			url = "http://" + pod.Status.PodIP + ":" + strconv.Itoa(rule.Port) + rule.Path
-		}
-
-		if rule.Method == "" {
-			rule.Method = v1alpha1.DefaultMethod
-		}
-
-		var respStr string
-		var err error
-		switch rule.Method {
-		case "get", "Get", "GET":
-			respStr, err = utils.RestApiGet(url)
-		case "post", "Post", "POST":
-			if rule.Body == "" {
-				glog.Errorf("FAILURE: Body NOT SET[%v]", rule)
-				return false, fmt.Sprintf("FAILURE: Body NOT SET[%v]", rule)
-			}
-			respStr, err = utils.RestApiPost(url, rule.Body)
+	//超时后按 timeoutPolicy 处理，Allow 与 Delete 不再执行规则，Deny 保持隔离继续执行规则
+	if record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name); err == nil && record != nil && sm.TimeoutExceeded(pod, record) {
+		if record.TimeoutPolicy != v1alpha1.TimeoutPolicyDeny {
+			return true, "{smoothing timeout " + record.TimeoutDuration().String() + " policy " + record.TimeoutPolicy + "}", decisionTimeout
 		}
+	}
 
-		if err != nil {
-			reasons = append(reasons, "{"+err.Error()+"}")
-		} else {
-			reasons = append(reasons, "{"+rule.Method+" "+strconv.Itoa(rule.Port)+rule.Path+" "+respStr+"}")
-			if respStr != strings.TrimSpace(rule.Expect) {
-				allowed = false
-			}
+	//关闭就绪门，Service摘除endpoint，无需应用配合
+	if hasReadinessGate(&pod) && readinessGateStatus(&pod) != corev1.ConditionFalse {
+		if err := sm.SetReadinessGate(pod, corev1.ConditionFalse, GateReasonSmoothing, "Smoothing requested by "+requestedBy); err == nil {
+			sm.Eventf(pod, smoothRecordName(smConfig), corev1.EventTypeNormal, EventTrafficIsolated, "Traffic isolated, readiness gate %s set False", v1alpha1.ReadinessGateType)
 		}
+	}
 
-		if !allowed {
-			break
-		}
+	async := sm.AsyncEvaluation(smConfig)
+	var allowed bool
+	var reasons []string
+	var decision string
+	if async {
+		allowed, reasons, decision = sm.CachedRuleResults(pod, smConfig)
+	} else {
+		allowed, reasons, decision = sm.EvaluateRules(pod, smConfig)
+	}
+	switch decision {
+	case decisionInvalidRule, decisionDeadline, decisionPending, decisionStoreError:
+		return false, strings.Join(reasons, ","), decision
 	}
 
 	//Rod状态
@@ -281,25 +415,36 @@ func (sm *SmoothManager) SmoothConfigExec(pod corev1.Pod) (bool, string) {
 	//流量已隔离，修改pod标签，避免影响副本计数
 	if !healthz && smConfig.Spec.SmLabel != "" {
 		if pod.Labels[smConfig.Spec.SmLabel] != "smoothed" {
+			// keep the value to restore on abort
+			if record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name); err == nil && record != nil && record.SmLabelValue == "" {
+				record.SmLabelValue = pod.Labels[smConfig.Spec.SmLabel]
+				if err := model.SetPodRecord(sm.Store, pod.Namespace, pod.Name, record); err != nil {
+					glog.Errorf("FAILURE: SET[%s]: %v", storeKey(model.KindPod, pod.Namespace, pod.Name), err)
+				}
+			}
 			pod.Labels[smConfig.Spec.SmLabel] = "smoothed"
 			playLoadBytes, _ := json.Marshal(map[string]interface{}{"metadata": map[string]map[string]string{"labels": pod.Labels}})
 			_, err := sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Patch(sm.Ctx, pod.Name, types.StrategicMergePatchType, playLoadBytes, metav1.PatchOptions{})
 			if err != nil {
 				reasons = append(reasons, "{smoothLabel set ["+err.Error()+"]}")
 				allowed = false
+				decision = decisionLabelError
 			} else {
-				if valueSmLabeled == "" {
+				sm.Eventf(pod, smoothRecordName(smConfig), corev1.EventTypeNormal, EventTrafficIsolated, "Traffic isolated, label %s=smoothed applied", smConfig.Spec.SmLabel)
+				if !smLabeled {
 					smConfigByte, err := json.Marshal(smConfig)
 					if err != nil {
-						glog.Infof("FAILURE: Marshal SmConfig[%s:%s]", smConfig, err.Error())
+						glog.Infof("FAILURE: Marshal SmConfig[%v:%s]", smConfig, err.Error())
 						reasons = append(reasons, "{SmConfig Marshal ["+err.Error()+"]}")
 						allowed = false
+						decision = decisionLabelError
 					}
-					err = sm.ClientRedis.Client.SetNX(sm.ClientRedis.Ctx, keySmLabeled, string(smConfigByte), 0).Err()
+					_, err = sm.Store.SetNX(model.KindLabel, pod.Namespace, pod.Name, string(smConfigByte))
 					if err != nil {
-						glog.Infof("FAILURE: SET[%s:%s]", keySmLabeled, valueSmLabeled)
+						glog.Infof("FAILURE: SET[%s:%s]", storeKey(model.KindLabel, pod.Namespace, pod.Name), smConfig.Name)
 						reasons = append(reasons, "{SmConfig set ["+err.Error()+"]}")
 						allowed = false
+						decision = decisionLabelError
 					}
 				}
 			}
@@ -307,58 +452,183 @@ func (sm *SmoothManager) SmoothConfigExec(pod corev1.Pod) (bool, string) {
 	}
 
 	if !allowed || healthz {
-		return false, strings.Join(reasons, ",")
+		if allowed {
+			decision = decisionPodReady
+		}
+		return false, strings.Join(reasons, ","), decision
+	} else if async {
+		//避免Terminal状态网络回收对请求的影响，不阻塞准入，等待下次删除
+		if wait := sm.notReadyWait(pod); wait > 0 {
+			return false, strings.Join(append(reasons, "{pod NotReady wait "+wait.Round(time.Second).String()+"}"), ","), decisionPending
+		}
 	} else {
 		//避免Terminal状态网络回收对请求的影响
-		var keyPodNotReady = "ADMITEE_SMOOTH_NOTREADY_" + pod.Namespace + "_" + pod.Name
-		vaulePodNotReady, _ := sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, keyPodNotReady).Result()
+		vaulePodNotReady, _ := sm.Store.Get(model.KindNotReady, pod.Namespace, pod.Name)
 		if vaulePodNotReady == "" {
-			time.Sleep(time.Duration(5) * time.Second)
+			select {
+			case <-time.After(notReadyDelay):
+			case <-sm.Ctx.Done():
+			}
 
 			value := strconv.FormatInt(time.Now().Unix(), 10)
-			err := sm.ClientRedis.Client.SetNX(sm.ClientRedis.Ctx, keyPodNotReady, value, 0).Err()
-			if err == nil {
-				glog.Infof("SUCCESS: SET[%s:%s]", keyPodNotReady, value)
+			ok, err := sm.Store.SetNX(model.KindNotReady, pod.Namespace, pod.Name, value)
+			if err == nil && ok {
+				glog.Infof("SUCCESS: SET[%s:%s]", storeKey(model.KindNotReady, pod.Namespace, pod.Name), value)
 			}
 		}
 	}
 
-	return allowed, strings.Join(reasons, ",")
+	return allowed, strings.Join(reasons, ","), decision
 }
 
-func (sm *SmoothManager) GetSmoothConfig(pod corev1.Pod) (*v1alpha1.Smooth, error) {
-	namespace := pod.Namespace
-	kindTarget, nameTarget, err := sm.GetTarget(pod)
+// EvaluateRules probe the rules of smConfig for pod in order, results are kept in its PodRecord
+func (sm *SmoothManager) EvaluateRules(pod corev1.Pod, smConfig *v1alpha1.Smooth) (bool, []string, string) {
+	var allowed = true
+	var decision = decisionRulesPassed
+	var reasons []string
+	var results []v1alpha1.RuleResult
+	var vars = sm.NewRuleVars(pod)
+	for _, rule := range smConfig.Spec.Rules {
+		// metrics keep the path template, rendered paths may contain pod names
+		rulePath := rule.Path
+		rule, method, url, tlsConfig, err := sm.PrepareRule(pod, smConfig, rule, vars)
+		if err != nil {
+			return false, []string{err.Error()}, decisionInvalidRule
+		}
+		probeStart := time.Now()
+		resp, err := ProbeRule(sm.Ctx, method, url, rule, tlsConfig)
+
+		var result = v1alpha1.RuleResult{
+			Pod:       pod.Name,
+			Rule:      rule.Method + " " + strconv.Itoa(rule.Port) + rule.Path,
+			Expect:    DescribeExpect(rule),
+			Passed:    true,
+			ProbeTime: metav1.Now(),
+		}
+		var probeResult = "expect"
+		if err != nil && sm.Ctx.Err() != nil {
+			// out of time, the rules left can not be skipped
+			result.Passed = false
+			result.Error = "exceed admission deadline[" + sm.Deadline.String() + "]: " + err.Error()
+			results = append(results, result)
+			sm.SetRuleResults(pod, results)
+			metrics.RuleProbeDuration.WithLabelValues(rulePath, "error").Observe(time.Since(probeStart).Seconds())
+			sm.Eventf(pod, smoothRecordName(smConfig), corev1.EventTypeWarning, EventRuleFailed, "Rule[%s] exceed admission deadline[%v]", result.Rule, sm.Deadline)
+			glog.Errorf("FAILURE: POD[%s] Rule[%s] exceed admission deadline[%v]: %v", pod.Namespace+"/"+pod.Name, result.Rule, sm.Deadline, err)
+			return false, append(reasons, "{"+result.Rule+" exceed admission deadline["+sm.Deadline.String()+"]}"), decisionDeadline
+		} else if err != nil {
+			reasons = append(reasons, "{"+err.Error()+"}")
+			result.Error = err.Error()
+			probeResult = "error"
+		} else {
+			reasons = append(reasons, "{"+rule.Method+" "+strconv.Itoa(rule.Port)+rule.Path+" "+resp.Body+"}")
+			result.Response = truncateResponse(resp.Body)
+			passed, failed, err := MatchResponse(rule, resp)
+			if err != nil {
+				glog.Errorf("FAILURE: Matcher[%v]: %v", rule, err)
+				return false, []string{"FAILURE: " + err.Error()}, decisionInvalidRule
+			}
+			if !passed {
+				allowed = false
+				decision = decisionRuleUnexpected
+				result.Passed = false
+				result.Expect = failed
+				probeResult = "unexpected"
+				sm.Eventf(pod, smoothRecordName(smConfig), corev1.EventTypeWarning, EventRuleFailed, "Rule[%s] status[%d] response[%s] expect[%s]", result.Rule, resp.StatusCode, result.Response, result.Expect)
+			}
+		}
+		metrics.RuleProbeDuration.WithLabelValues(rulePath, probeResult).Observe(time.Since(probeStart).Seconds())
+		results = append(results, result)
+
+		if !allowed {
+			break
+		}
+	}
+	sm.SetRuleResults(pod, results)
+	return allowed, reasons, decision
+}
+
+// PrepareRule default, render and validate rule for pod, returns the rule, http method, url and tls config
+func (sm *SmoothManager) PrepareRule(pod corev1.Pod, smConfig *v1alpha1.Smooth, rule v1alpha1.Rule, vars RuleVars) (v1alpha1.Rule, string, string, *tls.Config, error) {
+	if rule.Port >= 65535 {
+		glog.Errorf("FAILURE: Port OutOfRange 0~65535 [%v]", rule.Port)
+		return rule, "", "", nil, fmt.Errorf("FAILURE: Port OutOfRange 0~65535 [%v]", rule.Port)
+	} else if rule.Port == 0 {
+		rule.Port = int(pod.Spec.Containers[0].Ports[0].ContainerPort)
+		if rule.Port == 0 {
+			rule.Port = v1alpha1.DefaultPort
+		}
+	}
+
+	if rule.Path == "" {
+		return rule, "", "", nil, fmt.Errorf("FAILURE: Path NOT SET[%v]", rule)
+	}
+
+	rendered, err := RenderRule(rule, vars)
 	if err != nil {
-		return nil, err
+		glog.Errorf("FAILURE: %v", err)
+		return rule, "", "", nil, fmt.Errorf("FAILURE: %v", err)
 	}
+	rule = rendered
 
-	var gvr = schema.GroupVersionResource{
-		Group:    v1alpha1.Group,
-		Version:  v1alpha1.Version,
-		Resource: v1alpha1.Resource,
+	if rule.Scheme == "" {
+		rule.Scheme = v1alpha1.DefaultScheme
+	} else if rule.Scheme != "http" && rule.Scheme != "https" {
+		return rule, "", "", nil, fmt.Errorf("FAILURE: Scheme NOT SUPPORTED[%v]", rule.Scheme)
 	}
-	list, err := sm.ClientSmooth.Resource(gvr).Namespace(namespace).List(sm.Ctx, metav1.ListOptions{})
+	tlsConfig, err := sm.RuleTLSConfig(smConfig.Namespace, rule)
 	if err != nil {
-		return nil, err
+		glog.Errorf("FAILURE: TLS[%v]: %v", rule, err)
+		return rule, "", "", nil, err
 	}
 
-	data, err := list.MarshalJSON()
+	var url string
+	if rule.Address != "" {
+		url = rule.Scheme + "://" + rule.Address + ":" + strconv.Itoa(rule.Port) + rule.Path
+	} else {
+		url = rule.Scheme + "://" + pod.Status.PodIP + ":" + strconv.Itoa(rule.Port) + rule.Path
+	}
+
+	if rule.Method == "" {
+		rule.Method = v1alpha1.DefaultMethod
+	}
+
+	var method string
+	switch rule.Method {
+	case "get", "Get", "GET":
+		method = http.MethodGet
+	case "post", "Post", "POST":
+		if rule.Body == "" {
+			glog.Errorf("FAILURE: Body NOT SET[%v]", rule)
+			return rule, "", "", nil, fmt.Errorf("FAILURE: Body NOT SET[%v]", rule)
+		}
+		method = http.MethodPost
+	default:
+		return rule, "", "", nil, fmt.Errorf("FAILURE: Method NOT SUPPORTED[%v]", rule.Method)
+	}
+	return rule, method, url, tlsConfig, nil
+}
+
+func (sm *SmoothManager) GetSmoothConfig(pod corev1.Pod) (*v1alpha1.Smooth, error) {
+	kindTarget, nameTarget, err := sm.GetTarget(pod)
 	if err != nil {
 		return nil, err
 	}
-	var smList v1alpha1.SmoothList
-	if err := json.Unmarshal(data, &smList); err != nil {
+
+	smooths, err := sm.ListSmooths(pod.Namespace)
+	if err != nil {
 		return nil, err
 	}
 
-	for _, sm := range smList.Items {
-		if sm.Spec.TargetRef.Name == nameTarget && sm.Spec.TargetRef.Kind == kindTarget {
-			return &sm, err
-		}
+	smConfig, matched := ResolveSmooth(smooths, pod, kindTarget, nameTarget)
+	if len(matched) > 1 {
+		glog.Infof("MESSAGE: POD[%s/%s] Smooths Matched[%v] Applied[%s]", pod.Namespace, pod.Name, len(matched), smConfig.Name)
 	}
-
-	return nil, err
+	if smConfig == nil {
+		// ClusterSmooth is the fallback of pods without a Smooth in their namespace
+		return sm.GetClusterSmoothConfig(pod)
+	}
+	return smConfig, nil
 }
 
 func (sm *SmoothManager) GetTarget(pod corev1.Pod) (targetKind string, targetName string, err error) {
@@ -368,7 +638,7 @@ func (sm *SmoothManager) GetTarget(pod corev1.Pod) (targetKind string, targetNam
 	}
 
 	switch orKind {
-	case "DaemonSet":
+	case "DaemonSet", "StatefulSet":
 		return orKind, orName, err
 	case "ReplicaSet":
 		rsName := orName
@@ -388,37 +658,77 @@ func (sm *SmoothManager) CountSmoothingPodsByOwnerReferenceName(namespace string
 	var countUpdate int
 	var err error
 
-	// get keys of smoothing pods by ownerReferenceName reg
-	var keys []string
-	reg := "ADMITEE_SMOOTH_POD_" + namespace + "_" + ownerReferenceName + "*"
-	keys, err = sm.ClientRedis.Client.Keys(sm.ClientRedis.Ctx, reg).Result()
+	// get smoothing pods in namespace
+	var entries []model.Entry
+	entries, err = sm.Store.List(model.KindPod, namespace)
 	if err != nil {
-		glog.Errorf("FAILURE: POD KEYS[%s]: %v", reg, err)
+		glog.Errorf("FAILURE: POD LIST[%s]: %v", namespace, err)
+		return countUpdate, err
 	}
 
 	// match pod by ownerReferenceName
-	for _, v := range keys {
-		var result string
-		result, err = sm.ClientRedis.Client.Get(sm.ClientRedis.Ctx, v).Result()
+	for _, entry := range entries {
+		record, err := model.ParsePodRecord(entry.Value)
 		if err != nil {
-			glog.Errorf("FAILURE: GET[%s]: %v", v, err)
-			return countUpdate, err
+			glog.Errorf("FAILURE: Parse[%s]: %v", storeKey(model.KindPod, entry.Namespace, entry.Name), err)
+			continue
 		}
-		podInfo := strings.Split(result, "_")
-		if podInfo[1] == ownerReferenceName {
+		// timed out pods gave up smoothing, they no longer hold a slot of the rollout
+		if record.OwnerName == ownerReferenceName && !record.TimedOut {
 			countUpdate++
 		}
 	}
 	return countUpdate, err
 }
 
-func returnAdmissionResponse(allowed bool, reason string) *v1beta1.AdmissionResponse {
+// CountReadySmoothingPods smoothing pods of the owner of pod besides pod itself that are still Ready,
+// unready ones already left the ready replicas and the disruptionsAllowed of PDBs
+func (sm *SmoothManager) CountReadySmoothingPods(pod corev1.Pod) (int, error) {
+	var countReady int
+	_, ownerReferenceName, _ := utils.GetOwnerReference(pod)
+	entries, err := sm.Store.List(model.KindPod, pod.Namespace)
+	if err != nil {
+		glog.Errorf("FAILURE: POD LIST[%s]: %v", pod.Namespace, err)
+		return countReady, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name == pod.Name {
+			continue
+		}
+		record, err := model.ParsePodRecord(entry.Value)
+		if err != nil {
+			glog.Errorf("FAILURE: Parse[%s]: %v", storeKey(model.KindPod, entry.Namespace, entry.Name), err)
+			continue
+		}
+		if record.OwnerName != ownerReferenceName || record.TimedOut {
+			continue
+		}
+		smoothing, err := sm.ClientKubeSet.CoreV1().Pods(pod.Namespace).Get(sm.Ctx, entry.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return countReady, err
+		}
+		if podReady(smoothing) {
+			countReady++
+		}
+	}
+	return countReady, nil
+}
+
+// storeKey readable name of a record for logs
+func storeKey(kind model.Kind, namespace string, name string) string {
+	return string(kind) + "_" + namespace + "_" + name
+}
+
+func returnAdmissionResponse(allowed bool, reason string) *admissionv1.AdmissionResponse {
 	var result *metav1.Status
 	result = &metav1.Status{
 		Reason: metav1.StatusReason(reason),
 	}
 
-	return &v1beta1.AdmissionResponse{
+	return &admissionv1.AdmissionResponse{
 		Allowed: allowed,
 		Result:  result,
 	}
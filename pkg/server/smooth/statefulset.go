@@ -0,0 +1,76 @@
+package smooth
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func (sm *SmoothManager) VerifyDeletePodStatefulSet(namespace string, stsName string, podName string, countUpdate int) (bool, string) {
+	sts, err := sm.ClientKubeSet.AppsV1().StatefulSets(namespace).Get(sm.Ctx, stsName, metav1.GetOptions{})
+	if err != nil {
+		glog.Errorf("FAILURE: Get StatefulSet[%v]", err)
+		return false, "StatefulSet GET[" + err.Error() + "]"
+	}
+
+	var replicas, partition int
+	replicas = 1
+	if sts.Spec.Replicas != nil {
+		replicas = int(*sts.Spec.Replicas)
+	}
+
+	// maxUnavailable defaults to 1, same as the statefulset controller
+	maxUnavailable := intstr.FromInt(1)
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil {
+		if ru.MaxUnavailable != nil {
+			maxUnavailable = *ru.MaxUnavailable
+		}
+		if ru.Partition != nil {
+			partition = int(*ru.Partition)
+		}
+	}
+	countMaxuav, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, replicas, true)
+	if err != nil {
+		glog.Errorf("FAILURE: StatefulSet MaxUnavailable[%v]", err)
+		return false, "StatefulSet MaxUnavailable[" + err.Error() + "]"
+	}
+
+	ordinal := statefulSetPodOrdinal(podName)
+	switch {
+	case sts.Spec.PodManagementPolicy != appsv1.ParallelPodManagement:
+		// OrderedReady replaces pods one by one, each one waits for the previous to be Ready
+		countMaxuav = 1
+	case ordinal >= 0 && ordinal < partition:
+		// pods below partition are not rolled, only deleted by hand
+		countMaxuav = 1
+	case partition > 0 && countMaxuav > replicas-partition:
+		countMaxuav = replicas - partition
+	}
+	if countMaxuav < 1 {
+		countMaxuav = 1
+	}
+	glog.Infof("MESSAGE: StatefulSet[%s] SmoothCount[%v] MaxUnavailableCount[%v] Replicas[%v] MaxUnavailable[%v] Partition[%v] Ordinal[%v] PodManagementPolicy[%v]", stsName, countUpdate, countMaxuav, replicas, maxUnavailable.String(), partition, ordinal, sts.Spec.PodManagementPolicy)
+
+	//删除副本数大于等于最大不可用副本数时，拒绝删除
+	if countUpdate >= countMaxuav {
+		return false, "StatefulSet exceed maxUnavailable[" + strconv.Itoa(countUpdate) + "/" + strconv.Itoa(countMaxuav) + "]"
+	}
+	return true, "StatefulSet maxUnavailable[" + strconv.Itoa(countUpdate) + "/" + strconv.Itoa(countMaxuav) + "]"
+}
+
+// statefulSetPodOrdinal ordinal suffix of a StatefulSet pod name, -1 when it has none
+func statefulSetPodOrdinal(podName string) int {
+	i := strings.LastIndex(podName, "-")
+	if i < 0 {
+		return -1
+	}
+	ordinal, err := strconv.Atoi(podName[i+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
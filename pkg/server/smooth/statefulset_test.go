@@ -0,0 +1,175 @@
+package smooth
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+func TestVerifyDeletePodStatefulSet(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      appsv1.PodManagementPolicyType
+		rolling     *appsv1.RollingUpdateStatefulSetStrategy
+		replicas    *int32
+		podName     string
+		countUpdate int
+		allowed     bool
+		reason      string
+	}{
+		{
+			name:        "OrderedReady one at a time",
+			policy:      appsv1.OrderedReadyPodManagement,
+			rolling:     &appsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: intOrString(intstr.FromInt(3))},
+			replicas:    int32Ptr(10),
+			podName:     "web-9",
+			countUpdate: 1,
+			allowed:     false,
+			reason:      "StatefulSet exceed maxUnavailable[1/1]",
+		},
+		{
+			name:        "OrderedReady first pod",
+			podName:     "web-9",
+			replicas:    int32Ptr(10),
+			countUpdate: 0,
+			allowed:     true,
+			reason:      "StatefulSet maxUnavailable[0/1]",
+		},
+		{
+			name:        "Parallel integer maxUnavailable",
+			policy:      appsv1.ParallelPodManagement,
+			rolling:     &appsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: intOrString(intstr.FromInt(3))},
+			replicas:    int32Ptr(10),
+			podName:     "web-4",
+			countUpdate: 2,
+			allowed:     true,
+			reason:      "StatefulSet maxUnavailable[2/3]",
+		},
+		{
+			name:        "Parallel percent maxUnavailable rounds up",
+			policy:      appsv1.ParallelPodManagement,
+			rolling:     &appsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: intOrString(intstr.FromString("25%"))},
+			replicas:    int32Ptr(10),
+			podName:     "web-4",
+			countUpdate: 3,
+			allowed:     false,
+			reason:      "StatefulSet exceed maxUnavailable[3/3]",
+		},
+		{
+			name:        "Parallel without rollingUpdate defaults to 1",
+			policy:      appsv1.ParallelPodManagement,
+			replicas:    int32Ptr(10),
+			podName:     "web-4",
+			countUpdate: 1,
+			allowed:     false,
+			reason:      "StatefulSet exceed maxUnavailable[1/1]",
+		},
+		{
+			name:   "Parallel partition caps to pods above it",
+			policy: appsv1.ParallelPodManagement,
+			rolling: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition:      int32Ptr(8),
+				MaxUnavailable: intOrString(intstr.FromInt(5)),
+			},
+			replicas:    int32Ptr(10),
+			podName:     "web-9",
+			countUpdate: 2,
+			allowed:     false,
+			reason:      "StatefulSet exceed maxUnavailable[2/2]",
+		},
+		{
+			name:   "Parallel pod below partition one at a time",
+			policy: appsv1.ParallelPodManagement,
+			rolling: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition:      int32Ptr(8),
+				MaxUnavailable: intOrString(intstr.FromInt(5)),
+			},
+			replicas:    int32Ptr(10),
+			podName:     "web-3",
+			countUpdate: 1,
+			allowed:     false,
+			reason:      "StatefulSet exceed maxUnavailable[1/1]",
+		},
+		{
+			name:   "Parallel partition above replicas at least 1",
+			policy: appsv1.ParallelPodManagement,
+			rolling: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition:      int32Ptr(12),
+				MaxUnavailable: intOrString(intstr.FromInt(5)),
+			},
+			replicas:    int32Ptr(10),
+			podName:     "web-x",
+			countUpdate: 0,
+			allowed:     true,
+			reason:      "StatefulSet maxUnavailable[0/1]",
+		},
+		{
+			name:        "nil replicas is 1",
+			policy:      appsv1.ParallelPodManagement,
+			rolling:     &appsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: intOrString(intstr.FromString("50%"))},
+			podName:     "web-0",
+			countUpdate: 0,
+			allowed:     true,
+			reason:      "StatefulSet maxUnavailable[0/1]",
+		},
+		{
+			name:        "invalid maxUnavailable",
+			policy:      appsv1.ParallelPodManagement,
+			rolling:     &appsv1.RollingUpdateStatefulSetStrategy{MaxUnavailable: intOrString(intstr.FromString("ten"))},
+			replicas:    int32Ptr(10),
+			podName:     "web-0",
+			countUpdate: 0,
+			allowed:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas:            tt.replicas,
+					PodManagementPolicy: tt.policy,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+						Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+						RollingUpdate: tt.rolling,
+					},
+				},
+			}
+			sm := &SmoothManager{ClientKubeSet: fake.NewSimpleClientset(sts), Ctx: context.Background()}
+
+			allowed, reason := sm.VerifyDeletePodStatefulSet("default", "web", tt.podName, tt.countUpdate)
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v, reason %s", allowed, tt.allowed, reason)
+			}
+			if tt.reason != "" && reason != tt.reason {
+				t.Errorf("reason = %s, want %s", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestStatefulSetPodOrdinal(t *testing.T) {
+	tests := map[string]int{
+		"web-0":        0,
+		"web-12":       12,
+		"my-web-3":     3,
+		"web":          -1,
+		"web-x":        -1,
+		"web-":         -1,
+		"web-1-canary": -1,
+	}
+	for podName, want := range tests {
+		if got := statefulSetPodOrdinal(podName); got != want {
+			t.Errorf("statefulSetPodOrdinal(%s) = %d, want %d", podName, got, want)
+		}
+	}
+}
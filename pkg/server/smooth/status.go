@@ -0,0 +1,243 @@
+package smooth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/metrics"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const maxResponseLength = 256
+
+var smoothGVR = schema.GroupVersionResource{
+	Group:    v1alpha1.Group,
+	Version:  v1alpha1.Version,
+	Resource: v1alpha1.Resource,
+}
+
+// SyncSmoothStatus recompute status of a Smooth from the pod records in its namespace
+func (sm *SmoothManager) SyncSmoothStatus(smooth *v1alpha1.Smooth, podLister corelisters.PodLister) error {
+	var smoothingPods []v1alpha1.SmoothingPod
+	var ruleResults []v1alpha1.RuleResult
+
+	entries, err := sm.Store.List(model.KindPod, smooth.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record, err := model.ParsePodRecord(entry.Value)
+		if err != nil {
+			continue
+		}
+
+		kindTarget, nameTarget := record.TargetKind, record.TargetName
+		if kindTarget == "" {
+			// legacy records have no target
+			pod, err := podLister.Pods(entry.Namespace).Get(entry.Name)
+			if err != nil {
+				continue
+			}
+			kindTarget, nameTarget, err = sm.GetTarget(*pod)
+			if err != nil {
+				continue
+			}
+		}
+		if record.SmoothName != "" {
+			if record.SmoothName != smooth.Name {
+				continue
+			}
+		} else if kindTarget != smooth.Spec.TargetRef.Kind || nameTarget != smooth.Spec.TargetRef.Name {
+			continue
+		}
+
+		// status keeps seconds, sub-second times never compare equal to the status read back
+		smoothingPods = append(smoothingPods, v1alpha1.SmoothingPod{
+			Name:          entry.Name,
+			Attempts:      record.Count,
+			FirstSeen:     metav1.NewTime(record.FirstSeen.Truncate(time.Second)),
+			LastProbeTime: metav1.NewTime(record.LastProbe.Truncate(time.Second)),
+			RequestedBy:   record.RequestedBy,
+			Reason:        blockingReason(record.RuleResults),
+		})
+		ruleResults = append(ruleResults, record.RuleResults...)
+	}
+
+	status := v1alpha1.SmoothStatus{
+		ObservedGeneration: smooth.Generation,
+		SmoothingCount:     len(smoothingPods),
+		SmoothingPods:      smoothingPods,
+		LastRuleResults:    ruleResults,
+		Conditions:         append([]metav1.Condition{}, smooth.Status.Conditions...),
+	}
+	sort.Slice(status.SmoothingPods, func(i, j int) bool {
+		return status.SmoothingPods[i].Name < status.SmoothingPods[j].Name
+	})
+	kindLabel, targetLabel := smoothTargetLabels(*smooth)
+	metrics.SmoothingPods.WithLabelValues(smooth.Namespace, kindLabel, targetLabel).Set(float64(len(smoothingPods)))
+
+	targetFound := metav1.Condition{
+		Type:               v1alpha1.ConditionTargetFound,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: smooth.Generation,
+		Reason:             "TargetFound",
+		Message:            smooth.Spec.TargetRef.Kind + "/" + smooth.Spec.TargetRef.Name,
+	}
+	if !hasTargetRef(*smooth) {
+		targetFound.Reason = "Selector"
+		targetFound.Message = metav1.FormatLabelSelector(smooth.Spec.Selector)
+		if smooth.Spec.Selector == nil {
+			targetFound.Status = metav1.ConditionFalse
+			targetFound.Reason = "NoTarget"
+			targetFound.Message = "one of targetRef and selector is required"
+		}
+	} else if err := sm.GetTargetObject(smooth.Namespace, smooth.Spec.TargetRef.Kind, smooth.Spec.TargetRef.Name); err != nil {
+		targetFound.Status = metav1.ConditionFalse
+		targetFound.Reason = "TargetNotFound"
+		targetFound.Message = err.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, targetFound)
+
+	ready := metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: smooth.Generation,
+		Reason:             "Ready",
+	}
+	if err := ValidateRules(smooth.Spec.Rules); err != nil {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "InvalidRules"
+		ready.Message = err.Error()
+	} else if err := ValidatePolicy(smooth.Spec); err != nil {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "InvalidPolicy"
+		ready.Message = err.Error()
+	} else if targetFound.Status != metav1.ConditionTrue {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = targetFound.Reason
+		ready.Message = targetFound.Message
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+	meta.SetStatusCondition(&status.Conditions, sm.SmoothConflict(smooth, podLister))
+
+	if equality.Semantic.DeepEqual(smooth.Status, status) {
+		return nil
+	}
+	smooth.Status = status
+	return sm.UpdateSmoothStatus(smooth)
+}
+
+func (sm *SmoothManager) UpdateSmoothStatus(smooth *v1alpha1.Smooth) error {
+	data, err := json.Marshal(smooth)
+	if err != nil {
+		return err
+	}
+	var obj unstructured.Unstructured
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	_, err = sm.ClientSmooth.Resource(smoothGVR).Namespace(smooth.Namespace).UpdateStatus(sm.Ctx, &obj, metav1.UpdateOptions{})
+	return err
+}
+
+// GetTargetObject verify the workload referenced by targetRef exists
+func (sm *SmoothManager) GetTargetObject(namespace string, kind string, name string) error {
+	var err error
+	switch kind {
+	case "Deployment":
+		_, err = sm.ClientKubeSet.AppsV1().Deployments(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	case "ReplicaSet":
+		_, err = sm.ClientKubeSet.AppsV1().ReplicaSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	case "DaemonSet":
+		_, err = sm.ClientKubeSet.AppsV1().DaemonSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	case "StatefulSet":
+		_, err = sm.ClientKubeSet.AppsV1().StatefulSets(namespace).Get(sm.Ctx, name, metav1.GetOptions{})
+	default:
+		err = fmt.Errorf("Kind NOT SUPPORTED[%s]", kind)
+	}
+	return err
+}
+
+// ValidateRules same checks SmoothConfigExec applies before probing
+func ValidateRules(rules []v1alpha1.Rule) error {
+	for _, rule := range rules {
+		if rule.Port >= 65535 {
+			return fmt.Errorf("Port OutOfRange 0~65535 [%v]", rule.Port)
+		}
+		if rule.Path == "" {
+			return fmt.Errorf("Path NOT SET[%v]", rule)
+		}
+		if rule.Retries < 0 {
+			return fmt.Errorf("Retries must not be negative[%v]", rule.Retries)
+		}
+		if rule.Timeout != nil && rule.Timeout.Duration < 0 {
+			return fmt.Errorf("Timeout must not be negative[%v]", rule.Timeout.Duration)
+		}
+		if err := ValidateTemplates(rule); err != nil {
+			return err
+		}
+		for _, m := range rule.Matchers {
+			if err := ValidateMatcher(m); err != nil {
+				return err
+			}
+		}
+		switch rule.Scheme {
+		case "", "http":
+			if rule.TLS != nil {
+				return fmt.Errorf("TLS NOT SUPPORTED by Scheme[%s]", rule.Scheme)
+			}
+		case "https":
+		default:
+			return fmt.Errorf("Scheme NOT SUPPORTED[%s]", rule.Scheme)
+		}
+		switch rule.Method {
+		case "post", "Post", "POST":
+			if rule.Body == "" {
+				return fmt.Errorf("Body NOT SET[%v]", rule)
+			}
+		}
+	}
+	return nil
+}
+
+// SetRuleResults keep the last rule results and probe time in the pod record
+func (sm *SmoothManager) SetRuleResults(pod corev1.Pod, results []v1alpha1.RuleResult) {
+	record, err := model.GetPodRecord(sm.Store, pod.Namespace, pod.Name)
+	if err != nil || record == nil {
+		return
+	}
+	record.RuleResults = results
+	record.LastProbe = time.Now()
+	err = model.SetPodRecord(sm.Store, pod.Namespace, pod.Name, record)
+	if err != nil {
+		glog.Errorf("FAILURE: SET[%s]: %v", storeKey(model.KindPod, pod.Namespace, pod.Name), err)
+	}
+}
+
+func blockingReason(results []v1alpha1.RuleResult) string {
+	for _, result := range results {
+		if !result.Passed {
+			return "{" + result.Rule + " " + result.Response + "} expect[" + result.Expect + "]"
+		}
+	}
+	return ""
+}
+
+func truncateResponse(resp string) string {
+	if len(resp) > maxResponseLength {
+		return resp[:maxResponseLength] + "..."
+	}
+	return resp
+}
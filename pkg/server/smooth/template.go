@@ -0,0 +1,87 @@
+package smooth
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/utils"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RuleVars variables of rule templates, e.g. {"pod":"{{ .PodName }}","target":"{{ .TargetName }}"}
+type RuleVars struct {
+	PodName     string
+	Namespace   string
+	PodIP       string
+	NodeName    string
+	Labels      map[string]string
+	Annotations map[string]string
+	OwnerName   string
+	TargetKind  string
+	TargetName  string
+}
+
+func (sm *SmoothManager) NewRuleVars(pod corev1.Pod) RuleVars {
+	_, ownerName, _ := utils.GetOwnerReference(pod)
+	kindTarget, nameTarget, _ := sm.GetTarget(pod)
+	return RuleVars{
+		PodName:     pod.Name,
+		Namespace:   pod.Namespace,
+		PodIP:       pod.Status.PodIP,
+		NodeName:    pod.Spec.NodeName,
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+		OwnerName:   ownerName,
+		TargetKind:  kindTarget,
+		TargetName:  nameTarget,
+	}
+}
+
+// RenderRule expand templates of address, path, body and headers
+func RenderRule(rule v1alpha1.Rule, vars RuleVars) (v1alpha1.Rule, error) {
+	var err error
+	if rule.Address, err = renderTemplate("address", rule.Address, vars); err != nil {
+		return rule, err
+	}
+	if rule.Path, err = renderTemplate("path", rule.Path, vars); err != nil {
+		return rule, err
+	}
+	if rule.Body, err = renderTemplate("body", rule.Body, vars); err != nil {
+		return rule, err
+	}
+	if len(rule.Headers) > 0 {
+		headers := make(map[string]string, len(rule.Headers))
+		for key, value := range rule.Headers {
+			if headers[key], err = renderTemplate("header "+key, value, vars); err != nil {
+				return rule, err
+			}
+		}
+		rule.Headers = headers
+	}
+	return rule, nil
+}
+
+// ValidateTemplates same parsing RenderRule applies
+func ValidateTemplates(rule v1alpha1.Rule) error {
+	_, err := RenderRule(rule, RuleVars{})
+	return err
+}
+
+func renderTemplate(name string, text string, vars RuleVars) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Template %s[%s]: %v", name, text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("Template %s[%s]: %v", name, text, err)
+	}
+	return buf.String(), nil
+}
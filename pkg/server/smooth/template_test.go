@@ -0,0 +1,136 @@
+package smooth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"admitee/pkg/api/v1alpha1"
+)
+
+func TestRenderRule(t *testing.T) {
+	vars := RuleVars{
+		PodName:     "web-7d9f-x2k",
+		Namespace:   "default",
+		PodIP:       "10.0.0.7",
+		NodeName:    "node-1",
+		Labels:      map[string]string{"app": "web"},
+		Annotations: map[string]string{"example.com/drain-port": "9090"},
+		OwnerName:   "web-7d9f",
+		TargetKind:  "Deployment",
+		TargetName:  "web",
+	}
+
+	tests := []struct {
+		name    string
+		rule    v1alpha1.Rule
+		want    v1alpha1.Rule
+		wantErr string
+	}{
+		{
+			name: "no templates unchanged",
+			rule: v1alpha1.Rule{Address: "10.0.0.1", Path: "/drain", Body: `{"a":1}`},
+			want: v1alpha1.Rule{Address: "10.0.0.1", Path: "/drain", Body: `{"a":1}`},
+		},
+		{
+			name: "pod and target variables",
+			rule: v1alpha1.Rule{
+				Address: "{{ .PodIP }}",
+				Path:    "/drain/{{ .Namespace }}/{{ .PodName }}",
+				Body:    `{"node":"{{ .NodeName }}","owner":"{{ .OwnerName }}","target":"{{ .TargetKind }}/{{ .TargetName }}"}`,
+			},
+			want: v1alpha1.Rule{
+				Address: "10.0.0.7",
+				Path:    "/drain/default/web-7d9f-x2k",
+				Body:    `{"node":"node-1","owner":"web-7d9f","target":"Deployment/web"}`,
+			},
+		},
+		{
+			name: "labels and annotations",
+			rule: v1alpha1.Rule{Path: `/{{ .Labels.app }}/{{ index .Annotations "example.com/drain-port" }}`},
+			want: v1alpha1.Rule{Path: "/web/9090"},
+		},
+		{
+			name: "missing label is empty",
+			rule: v1alpha1.Rule{Path: "/{{ .Labels.version }}"},
+			want: v1alpha1.Rule{Path: "/"},
+		},
+		{
+			name: "headers rendered, fields untouched",
+			rule: v1alpha1.Rule{
+				Port:    8080,
+				Method:  "POST",
+				Headers: map[string]string{"X-Pod": "{{ .PodName }}", "Accept": "application/json"},
+			},
+			want: v1alpha1.Rule{
+				Port:    8080,
+				Method:  "POST",
+				Headers: map[string]string{"X-Pod": "web-7d9f-x2k", "Accept": "application/json"},
+			},
+		},
+		{
+			name:    "parse error",
+			rule:    v1alpha1.Rule{Path: "/{{ .PodName"},
+			wantErr: "Template path[/{{ .PodName]",
+		},
+		{
+			name:    "unknown variable",
+			rule:    v1alpha1.Rule{Body: "{{ .Pod }}"},
+			wantErr: "Template body[{{ .Pod }}]",
+		},
+		{
+			name:    "header error names the header",
+			rule:    v1alpha1.Rule{Headers: map[string]string{"X-Pod": "{{ .PodName }"}},
+			wantErr: "Template header X-Pod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderRule(tt.rule, vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenderRule error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderRule: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RenderRule = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRuleKeepsHeaders(t *testing.T) {
+	headers := map[string]string{"X-Pod": "{{ .PodName }}"}
+	if _, err := RenderRule(v1alpha1.Rule{Headers: headers}, RuleVars{PodName: "web-0"}); err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Pod"] != "{{ .PodName }}" {
+		t.Errorf("RenderRule changed the Smooth headers to %v", headers)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    v1alpha1.Rule
+		wantErr bool
+	}{
+		{name: "plain rule", rule: v1alpha1.Rule{Path: "/drain"}},
+		{name: "known variables", rule: v1alpha1.Rule{Address: "{{ .PodIP }}", Path: "/{{ .Labels.app }}"}},
+		{name: "unclosed action", rule: v1alpha1.Rule{Body: "{{ .PodName"}, wantErr: true},
+		{name: "unknown function", rule: v1alpha1.Rule{Path: "{{ upper .PodName }}"}, wantErr: true},
+		{name: "unknown field", rule: v1alpha1.Rule{Headers: map[string]string{"X": "{{ .Deployment }}"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTemplates(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTemplates() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package smooth
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/metrics"
+	"admitee/pkg/model"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ParseTimeout int values are hours, string values a duration such as "90m", 0 when not set
+func ParseTimeout(value intstr.IntOrString) (time.Duration, error) {
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return 0, fmt.Errorf("timeout[%d] must not be negative", value.IntVal)
+		}
+		return time.Duration(value.IntVal) * time.Hour, nil
+	}
+	if value.StrVal == "" {
+		return 0, nil
+	}
+	if hours, err := strconv.Atoi(value.StrVal); err == nil {
+		return ParseTimeout(intstr.FromInt(hours))
+	}
+	timeout, err := time.ParseDuration(value.StrVal)
+	if err != nil {
+		return 0, fmt.Errorf("timeout[%s]: %v", value.StrVal, err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("timeout[%s] must not be negative", value.StrVal)
+	}
+	return timeout, nil
+}
+
+// SmoothTimeout timeout of smConfig, DefaultTimeout hours when not set or invalid
+func SmoothTimeout(smConfig *v1alpha1.Smooth) time.Duration {
+	timeout, err := ParseTimeout(smConfig.Spec.Timeout)
+	if err != nil {
+		glog.Errorf("FAILURE: Smooth[%s/%s] %v", smConfig.Namespace, smConfig.Name, err)
+	}
+	if timeout <= 0 {
+		timeout = v1alpha1.DefaultTimeout * time.Hour
+	}
+	return timeout
+}
+
+// TimeoutPolicy timeoutPolicy of smConfig, Allow when not set
+func TimeoutPolicy(smConfig *v1alpha1.Smooth) string {
+	switch smConfig.Spec.TimeoutPolicy {
+	case v1alpha1.TimeoutPolicyDeny, v1alpha1.TimeoutPolicyDelete:
+		return smConfig.Spec.TimeoutPolicy
+	}
+	return v1alpha1.TimeoutPolicyAllow
+}
+
+// TimeoutExceeded smoothing of pod passed its timeout, reported once by an Event and admitee_smoothing_timeouts_total
+func (sm *SmoothManager) TimeoutExceeded(pod corev1.Pod, record *model.PodRecord) bool {
+	if time.Since(record.FirstSeen) < record.TimeoutDuration() {
+		return false
+	}
+	if record.TimeoutPolicy == "" {
+		// legacy records gave up on timeout, the delete was allowed afterwards
+		record.TimeoutPolicy = v1alpha1.TimeoutPolicyAllow
+	}
+	if record.TimedOut {
+		return true
+	}
+
+	record.TimedOut = true
+	if err := model.SetPodRecord(sm.Store, pod.Namespace, pod.Name, record); err != nil {
+		glog.Errorf("FAILURE: SET[%s]: %v", storeKey(model.KindPod, pod.Namespace, pod.Name), err)
+	}
+	kindTarget := record.TargetKind
+	if kindTarget == "" {
+		kindTarget, _, _ = sm.GetTarget(pod)
+	}
+	metrics.SmoothingTimeouts.WithLabelValues(pod.Namespace, kindTarget, record.TimeoutPolicy).Inc()
+	sm.Eventf(pod, record.SmoothName, corev1.EventTypeWarning, EventSmoothTimeout, "Smoothing timeout after %v and %d attempts, policy %s", record.TimeoutDuration(), record.Count, record.TimeoutPolicy)
+	glog.Infof("MESSAGE: POD[%s/%s] Smoothing Timeout[%v] Policy[%s]", pod.Namespace, pod.Name, record.TimeoutDuration(), record.TimeoutPolicy)
+
+	// Allow keeps the pod, it serves again until it is deleted; Deny keeps it isolated while the rules
+	// are probed further; Delete removes it anyway
+	if record.TimeoutPolicy == v1alpha1.TimeoutPolicyAllow {
+		smConfig, _, err := sm.PodSmoothConfig(pod)
+		if err != nil {
+			glog.Errorf("FAILURE: Get SmConfig[%s/%s]: %v", pod.Namespace, pod.Name, err)
+		}
+		if err := sm.RestoreTraffic(pod, smConfig, record, GateReasonTimeout, "Smoothing timeout after "+record.TimeoutDuration().String()); err != nil {
+			glog.Errorf("FAILURE: Restore POD[%s/%s]: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package smooth
+
+import (
+	"testing"
+
+	"admitee/pkg/model"
+)
+
+func TestCountSmoothingPodsSkipsTimedOut(t *testing.T) {
+	store := model.NewMemoryStore()
+	records := map[string]*model.PodRecord{
+		"web-0": {Namespace: "default", OwnerName: "web-7d9f"},
+		"web-1": {Namespace: "default", OwnerName: "web-7d9f", TimedOut: true, TimeoutPolicy: "Allow"},
+		"web-2": {Namespace: "default", OwnerName: "web-7d9f", TimedOut: true, TimeoutPolicy: "Deny"},
+		"api-0": {Namespace: "default", OwnerName: "api-5c4b"},
+	}
+	for name, record := range records {
+		if err := model.SetPodRecord(store, "default", name, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sm := &SmoothManager{Store: store}
+
+	count, err := sm.CountSmoothingPodsByOwnerReferenceName("default", "web-7d9f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("CountSmoothingPodsByOwnerReferenceName = %d, want 1 without timed out pods", count)
+	}
+}
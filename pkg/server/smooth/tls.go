@@ -0,0 +1,31 @@
+package smooth
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"admitee/pkg/api/v1alpha1"
+	"admitee/pkg/utils"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RuleTLSConfig trust configuration of an https rule, nil for system trust
+func (sm *SmoothManager) RuleTLSConfig(namespace string, rule v1alpha1.Rule) (*tls.Config, error) {
+	if rule.Scheme != "https" || rule.TLS == nil {
+		return nil, nil
+	}
+
+	var ca, cert, key []byte
+	if rule.TLS.SecretName != "" {
+		secret, err := sm.ClientKubeSet.CoreV1().Secrets(namespace).Get(sm.Ctx, rule.TLS.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("FAILURE: Secret GET[%s/%s]: %v", namespace, rule.TLS.SecretName, err)
+		}
+		ca = secret.Data[corev1.ServiceAccountRootCAKey]
+		cert = secret.Data[corev1.TLSCertKey]
+		key = secret.Data[corev1.TLSPrivateKeyKey]
+	}
+	return utils.NewTLSConfig(ca, cert, key, rule.TLS.ServerName, rule.TLS.InsecureSkipVerify)
+}
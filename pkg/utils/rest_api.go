@@ -2,7 +2,8 @@ package utils
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"crypto/tls"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -10,76 +11,67 @@ import (
 	"time"
 )
 
+// TimeOut seconds, default timeout of a request
 const TimeOut = 10
 
-func RestApiGet(url string) (string, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				conn, err := net.DialTimeout(netw, addr, time.Second*TimeOut)
-				if err != nil {
-					return nil, err
-				}
-				conn.SetDeadline(time.Now().Add(time.Second * TimeOut))
-				return conn, nil
-			},
-			ResponseHeaderTimeout: time.Second * TimeOut,
-		},
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return "", err
-	}
-	if resp != nil {
-		defer resp.Body.Close()
-	}
+// sharedTransport keeps alive connections of rules with the system trust,
+// dial, handshake and response are bounded by the ctx of each request
+var sharedTransport = &http.Transport{
+	Proxy:               http.ProxyFromEnvironment,
+	DialContext:         (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext,
+	MaxIdleConnsPerHost: 4,
+	IdleConnTimeout:     90 * time.Second,
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("FAILURE: Http status code[%v]", resp.StatusCode)
-	}
-	ret, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// newTransport tlsConfig is only used by https urls, nil for system trust.
+// Rules with their own tls.Config get a transport without keep-alive, released after the request
+func newTransport(tlsConfig *tls.Config) (*http.Transport, bool) {
+	if tlsConfig == nil {
+		return sharedTransport, false
 	}
+	transport := sharedTransport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DisableKeepAlives = true
+	return transport, true
+}
 
-	return strings.TrimSpace(string(ret)), nil
+// RestApiResponse status and headers are kept so rules can assert on them
+type RestApiResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
 }
 
-func RestApiPost(url string, body string) (string, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				conn, err := net.DialTimeout(netw, addr, time.Second*TimeOut)
-				if err != nil {
-					return nil, err
-				}
-				conn.SetDeadline(time.Now().Add(time.Second * TimeOut))
-				return conn, nil
-			},
-			ResponseHeaderTimeout: time.Second * TimeOut,
-		},
+// RestApiRequest any status code is returned without error, ctx bounds the whole request including dial and handshake
+func RestApiRequest(ctx context.Context, method string, url string, body string, header map[string]string, tlsConfig *tls.Config) (*RestApiResponse, error) {
+	transport, release := newTransport(tlsConfig)
+	if release {
+		defer transport.CloseIdleConnections()
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
+	client := &http.Client{Transport: transport}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer([]byte(body)))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if body != "" {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
 	}
-	req.Header.Add("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
-	}
-	if resp != nil {
-		defer resp.Body.Close()
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("FAILURE: Http status code[%v]", resp.StatusCode)
-	}
 	ret, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	return strings.TrimSpace(string(ret)), nil
+	return &RestApiResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       strings.TrimSpace(string(ret)),
+	}, nil
 }